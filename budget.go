@@ -0,0 +1,192 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is returned when new metric can not be admitted by Budget.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// maxEvictRetry is maximum number of eviction for admitting a single metric.
+const maxEvictRetry = 3
+
+// BudgetOpts is an option for creating Budget.
+type BudgetOpts struct {
+	// Max is maximum number of metrics across all vectors that use this budget. Mandatory!
+	Max int
+
+	// Evict make Budget delete the least recently edited metric to admit new one when Max is
+	// reached, instead of rejecting it. Metrics within vector reservation are never evicted.
+	Evict bool
+}
+
+// Budget is a limit of number of metrics shared by many vectors. Vector use it by setting
+// Opts.Budget. Every vector can reserve part of the budget with Opts.BudgetReserve, the rest is
+// shared by all vectors.
+type Budget struct {
+	opts BudgetOpts
+
+	mtx      sync.Mutex
+	members  map[*Vector]*budgetMember
+	reserved int // sum of reservations
+	shared   int // number of metrics that use shared part
+	evicted  int
+}
+
+type budgetMember struct {
+	count   int
+	reserve int
+	weight  float64
+}
+
+// NewBudget will create new Budget.
+func NewBudget(opts BudgetOpts) *Budget {
+	return &Budget{
+		opts:    opts,
+		members: make(map[*Vector]*budgetMember),
+	}
+}
+
+// Length return number of metrics across all vectors.
+func (b *Budget) Length() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	n := 0
+	for _, m := range b.members {
+		n += m.count
+	}
+	return n
+}
+
+// Evicted return number of metrics that have been evicted.
+func (b *Budget) Evicted() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.evicted
+}
+
+// Vectors implement VectorLister.
+func (b *Budget) Vectors() []*Vector {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	vectors := make([]*Vector, 0, len(b.members))
+	for v := range b.members {
+		vectors = append(vectors, v)
+	}
+	return vectors
+}
+
+func (b *Budget) add(v *Vector, reserve int, weight float64) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if weight <= 0 {
+		weight = 1
+	}
+	b.members[v] = &budgetMember{reserve: reserve, weight: weight}
+	b.reserved += reserve
+}
+
+// remove remove v that has no metric from the budget and give back its reservation.
+func (b *Budget) remove(v *Vector) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if m, ok := b.members[v]; ok {
+		b.reserved -= m.reserve
+		delete(b.members, v)
+	}
+}
+
+// acquire take a slot for new metric in v. It must not be called while holding lock of any vector.
+func (b *Budget) acquire(v *Vector) error {
+	for i := 0; ; i++ {
+		if b.tryAcquire(v) {
+			return nil
+		}
+		if !b.opts.Evict || i >= maxEvictRetry || !b.evictOne() {
+			return ErrBudgetExceeded
+		}
+	}
+}
+
+func (b *Budget) tryAcquire(v *Vector) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	m := b.members[v]
+	if m.count >= m.reserve {
+		capacity := b.opts.Max - b.reserved
+		if b.shared >= capacity {
+			return false
+		}
+		b.shared++
+	}
+	m.count++
+
+	return true
+}
+
+// release give back n slots of v. Vector lock may be held while calling it.
+func (b *Budget) release(v *Vector, n int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	m := b.members[v]
+	for ; n > 0 && m.count > 0; n-- {
+		m.count--
+		if m.count >= m.reserve {
+			b.shared--
+		}
+	}
+}
+
+// evictOne delete the metric with the highest idle time divided by vector weight, from vectors
+// that use more than its reservation. It return false when there is nothing to evict.
+func (b *Budget) evictOne() bool {
+	b.mtx.Lock()
+	candidates := make(map[*Vector]float64)
+	for v, m := range b.members {
+		if m.count > m.reserve {
+			candidates[v] = m.weight
+		}
+	}
+	b.mtx.Unlock()
+
+	var (
+		victim     *Vector
+		victimHash uint64
+		victimM    Metric
+		best       = -1.0
+	)
+	now := time.Now()
+	for v, weight := range candidates {
+		v.mtx.RLock()
+		for h, m := range v.metrics {
+			if score := float64(now.Sub(m.LastEdit())) / weight; score > best {
+				victim, victimHash, victimM, best = v, h, m, score
+			}
+		}
+		v.mtx.RUnlock()
+	}
+
+	if victim == nil || !victim.evict(victimHash, victimM) {
+		return false
+	}
+
+	b.mtx.Lock()
+	b.evicted++
+	b.mtx.Unlock()
+
+	return true
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget_Reject(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 3})
+	c1 := createBudgetCounter("c1", b, 0, 0)
+	c2 := createBudgetCounter("c2", b, 0, 0)
+
+	_, err := c1.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	_, err = c1.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.NoError(t, err)
+	_, err = c2.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+
+	_, err = c2.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.Error(t, err)
+
+	// existing metric is always admitted.
+	_, err = c1.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, b.Length())
+
+	c1.Delete(prometheus.Labels{"key": "a"})
+	assert.Equal(t, 2, b.Length())
+	_, err = c2.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.NoError(t, err)
+
+	c1.Reset()
+	assert.Equal(t, 2, b.Length())
+}
+
+func TestBudget_Reserve(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 3})
+	c1 := createBudgetCounter("c1", b, 0, 0)
+	c2 := createBudgetCounter("c2", b, 2, 0)
+
+	_, err := c1.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	_, err = c1.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.Error(t, err)
+
+	_, err = c2.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	_, err = c2.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.NoError(t, err)
+	_, err = c2.GetMetricWith(prometheus.Labels{"key": "c"})
+	assert.Error(t, err)
+}
+
+func TestBudget_Evict(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 2, Evict: true})
+	c1 := createBudgetCounter("c1", b, 0, 0)
+	c2 := createBudgetCounter("c2", b, 0, 0)
+
+	c1.With(prometheus.Labels{"key": "old"}).Inc()
+	time.Sleep(10 * time.Millisecond)
+	c1.With(prometheus.Labels{"key": "new"}).Inc()
+
+	_, err := c2.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, b.Length())
+	assert.Equal(t, 1, b.Evicted())
+	assert.Equal(t, 1, c1.Length())
+	assert.False(t, c1.Delete(prometheus.Labels{"key": "old"}))
+}
+
+func TestBudget_EvictWeight(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 2, Evict: true})
+	heavy := createBudgetCounter("heavy", b, 0, 100)
+	light := createBudgetCounter("light", b, 0, 1)
+
+	heavy.With(prometheus.Labels{"key": "a"}).Inc()
+	time.Sleep(10 * time.Millisecond)
+	light.With(prometheus.Labels{"key": "a"}).Inc()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := heavy.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, heavy.Length())
+	assert.Equal(t, 0, light.Length())
+}
+
+func TestBudget_EvictReserved(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 1, Evict: true})
+	c1 := createBudgetCounter("c1", b, 1, 0)
+	c2 := createBudgetCounter("c2", b, 0, 0)
+
+	c1.With(prometheus.Labels{"key": "a"}).Inc()
+	_, err := c2.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.Error(t, err)
+	assert.Equal(t, 1, c1.Length())
+}
+
+func TestBudget_GC(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 10})
+	c := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:   "c",
+		Help:   "help",
+		Expire: time.Millisecond,
+		Budget: b,
+	})
+
+	c.With(prometheus.Labels{"key": "a"}).Inc()
+	time.Sleep(5 * time.Millisecond)
+	c.GC()
+	assert.Equal(t, 0, b.Length())
+}
+
+func TestBudget_Concurrent(t *testing.T) {
+	b := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 5, Evict: true})
+	c1 := createBudgetCounter("c1", b, 0, 0)
+	c2 := createBudgetCounter("c2", b, 0, 0)
+
+	var wg sync.WaitGroup
+	for _, c := range []*dynamicvector.Counter{c1, c2} {
+		wg.Add(1)
+		go func(c *dynamicvector.Counter) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if m, err := c.GetMetricWith(prometheus.Labels{"key": string(rune('a' + i%26))}); err == nil {
+					m.Inc()
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	assert.True(t, b.Length() <= 5)
+	assert.Equal(t, b.Length(), c1.Length()+c2.Length())
+}
+
+func createBudgetCounter(name string, b *dynamicvector.Budget, reserve int, weight float64) *dynamicvector.Counter {
+	return dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:          name,
+		Help:          "help",
+		Budget:        b,
+		BudgetReserve: reserve,
+		BudgetWeight:  weight,
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// maxCreationSites is maximum number of creation sites kept by vector. New sites are dropped
+// once it is reached.
+const maxCreationSites = 1000
+
+// pkgPrefix is function name prefix of this package. Frames with it are skipped when looking for
+// creation site.
+var pkgPrefix = func() string {
+	name := runtime.FuncForPC(reflect.ValueOf(NewVector).Pointer()).Name()
+	return name[:strings.LastIndex(name, ".")+1]
+}()
+
+// CreationSite is a call site outside this package that create new metrics.
+type CreationSite struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+
+	// Keys is label keys set by created metrics, separated by comma.
+	Keys string `json:"keys"`
+
+	// Sampled is number of sampled creations and Estimated is estimation of all creations.
+	Sampled   int `json:"sampled"`
+	Estimated int `json:"estimated"`
+}
+
+// CreationSites return sampled call sites that create new metrics, ordered by number of creations,
+// highest first. It is empty unless Opts.CallerSampleRate is set.
+func (v *Vector) CreationSites() []CreationSite {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	sites := make([]CreationSite, 0, len(v.sites))
+	for k, n := range v.sites {
+		sites = append(sites, CreationSite{
+			Function:  k.function,
+			File:      k.file,
+			Line:      k.line,
+			Keys:      k.keys,
+			Sampled:   n,
+			Estimated: n * v.opts.CallerSampleRate,
+		})
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Sampled != sites[j].Sampled {
+			return sites[i].Sampled > sites[j].Sampled
+		}
+		if sites[i].File != sites[j].File {
+			return sites[i].File < sites[j].File
+		}
+		if sites[i].Line != sites[j].Line {
+			return sites[i].Line < sites[j].Line
+		}
+		return sites[i].Keys < sites[j].Keys
+	})
+
+	return sites
+}
+
+// sampleCaller record caller of metric creation with label values. It must be called with write lock.
+func (v *Vector) sampleCaller(values []string) {
+	if v.opts.CallerSampleRate <= 0 {
+		return
+	}
+	v.creations++
+	if v.creations%v.opts.CallerSampleRate != 0 {
+		return
+	}
+
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+
+	var site siteKey
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) {
+			site = siteKey{function: frame.Function, file: frame.File, line: frame.Line}
+			break
+		}
+		if !more {
+			break
+		}
+	}
+
+	var keys []string
+	for i, value := range values {
+		if value != "" {
+			keys = append(keys, v.labels.Keys[i])
+		}
+	}
+	sort.Strings(keys)
+	site.keys = strings.Join(keys, ",")
+
+	if _, ok := v.sites[site]; ok || len(v.sites) < maxCreationSites {
+		v.sites[site]++
+	}
+}
+
+type siteKey struct {
+	function string
+	file     string
+	line     int
+	keys     string
+}
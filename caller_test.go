@@ -0,0 +1,57 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_CreationSites(t *testing.T) {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "c", Help: "help", CallerSampleRate: 2})
+	for i := 0; i < 10; i++ {
+		cv.With(prometheus.Labels{"request_id": strconv.Itoa(i)}).Inc()
+	}
+	for i := 0; i < 4; i++ {
+		createWithCode(cv, strconv.Itoa(i))
+	}
+	// existing metric is not sampled.
+	cv.With(prometheus.Labels{"request_id": "0"}).Inc()
+
+	sites := cv.CreationSites()
+	if assert.Equal(t, 2, len(sites)) {
+		assert.Equal(t, "github.com/rolandhawk/dynamicvector_test.TestVector_CreationSites", sites[0].Function)
+		assert.Contains(t, sites[0].File, "caller_test.go")
+		assert.Equal(t, 21, sites[0].Line)
+		assert.Equal(t, "request_id", sites[0].Keys)
+		assert.Equal(t, 5, sites[0].Sampled)
+		assert.Equal(t, 10, sites[0].Estimated)
+
+		assert.Equal(t, "github.com/rolandhawk/dynamicvector_test.createWithCode", sites[1].Function)
+		assert.Equal(t, "code,method", sites[1].Keys)
+		assert.Equal(t, 2, sites[1].Sampled)
+	}
+
+	w := httptest.NewRecorder()
+	dynamicvector.NewDebugHandler(dynamicvector.VectorList{cv.Vector}, dynamicvector.DebugOpts{}).
+		ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors?name=c", nil))
+	assert.Contains(t, w.Body.String(), "createWithCode")
+}
+
+func TestVector_CreationSites_Disabled(t *testing.T) {
+	cv := createCounter(0)
+	cv.With(prometheus.Labels{"label1": "a"}).Inc()
+	assert.Empty(t, cv.CreationSites())
+}
+
+func createWithCode(cv *dynamicvector.Counter, code string) {
+	cv.With(prometheus.Labels{"method": "GET", "code": code}).Inc()
+}
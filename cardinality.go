@@ -0,0 +1,216 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// defaultTop is number of top values shown by cardinality handler.
+const defaultTop = 10
+
+// Cardinality is a report of label values in a vector.
+type Cardinality struct {
+	// Name is fully-qualified name of the vector.
+	Name string `json:"name"`
+
+	// Length is number of metrics in vector and MaxLength is its limit.
+	Length    int `json:"length"`
+	MaxLength int `json:"max_length"`
+
+	// Keys contain report for every label key, ordered by number of distinct values, highest first.
+	Keys []KeyCardinality `json:"keys"`
+}
+
+// KeyCardinality is a report of values of a single label key.
+type KeyCardinality struct {
+	Key string `json:"key"`
+
+	// Distinct is number of distinct non empty values.
+	Distinct int `json:"distinct"`
+
+	// Empty is number of metrics that leave this key empty.
+	Empty int `json:"empty"`
+
+	// Top is values with the highest number of metrics, highest first.
+	Top []ValueCount `json:"top,omitempty"`
+}
+
+// ValueCount is number of metrics that have a label value.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Cardinality return label values report of this vector, including at most top values for each
+// label key. Zero top mean no values are reported.
+func (v *Vector) Cardinality(top int) (Cardinality, error) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	c := Cardinality{
+		Name:      v.Name(),
+		Length:    v.Length(),
+		MaxLength: v.meta().opts.MaxLength,
+	}
+
+	counts := make(map[string]map[string]int, len(v.labels.Keys))
+	for _, key := range v.labels.Keys {
+		counts[key] = make(map[string]int)
+	}
+	for _, m := range v.metrics {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			return Cardinality{}, err
+		}
+		for _, lp := range metric.Label {
+			if values, ok := counts[lp.GetName()]; ok && lp.GetValue() != "" {
+				values[lp.GetValue()]++
+			}
+		}
+	}
+
+	for _, key := range v.labels.Keys {
+		kc := KeyCardinality{Key: key, Distinct: len(counts[key]), Empty: len(v.metrics)}
+		for value, n := range counts[key] {
+			kc.Empty -= n
+			kc.Top = append(kc.Top, ValueCount{Value: value, Count: n})
+		}
+
+		sort.Slice(kc.Top, func(i, j int) bool {
+			if kc.Top[i].Count != kc.Top[j].Count {
+				return kc.Top[i].Count > kc.Top[j].Count
+			}
+			return kc.Top[i].Value < kc.Top[j].Value
+		})
+		if len(kc.Top) > top {
+			kc.Top = kc.Top[:top]
+		}
+		if len(kc.Top) == 0 {
+			kc.Top = nil
+		}
+
+		c.Keys = append(c.Keys, kc)
+	}
+	sort.SliceStable(c.Keys, func(i, j int) bool { return c.Keys[i].Distinct > c.Keys[j].Distinct })
+
+	return c, nil
+}
+
+// NewCardinalityHandler return http.Handler that render cardinality report of vectors as plain
+// text. It is meant to be mounted at /debug/cardinality. The handler accept these query parameters:
+//   - name: only report vector with this fully-qualified name.
+//   - top: number of top values for each label key, default is 10.
+func NewCardinalityHandler(vl VectorLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		top := defaultTop
+		if s := q.Get("top"); s != "" {
+			var err error
+			if top, err = parseInt(s); err != nil {
+				http.Error(w, "invalid top: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var reports []Cardinality
+		for _, v := range vl.Vectors() {
+			if name := q.Get("name"); name != "" && name != v.Name() {
+				continue
+			}
+
+			c, err := v.Cardinality(top)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			reports = append(reports, c)
+		}
+		sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, c := range reports {
+			if c.MaxLength > 0 {
+				fmt.Fprintf(w, "%s: %d/%d series\n", c.Name, c.Length, c.MaxLength)
+			} else {
+				fmt.Fprintf(w, "%s: %d series\n", c.Name, c.Length)
+			}
+			for _, kc := range c.Keys {
+				fmt.Fprintf(w, "  %s: %d distinct, %d empty\n", kc.Key, kc.Distinct, kc.Empty)
+				for _, vc := range kc.Top {
+					fmt.Fprintf(w, "    %q: %d\n", vc.Value, vc.Count)
+				}
+			}
+			fmt.Fprintln(w)
+		}
+	})
+}
+
+// CardinalityCollector is a prometheus.Collector that expose cardinality of vectors as metrics.
+type CardinalityCollector struct {
+	vl VectorLister
+
+	series   *prometheus.Desc
+	max      *prometheus.Desc
+	distinct *prometheus.Desc
+	empty    *prometheus.Desc
+}
+
+// NewCardinalityCollector will create new CardinalityCollector for vectors in vl.
+func NewCardinalityCollector(vl VectorLister) *CardinalityCollector {
+	return &CardinalityCollector{
+		vl: vl,
+		series: prometheus.NewDesc("dynamicvector_series",
+			"Number of metrics in vector.", []string{"vector"}, nil),
+		max: prometheus.NewDesc("dynamicvector_max_series",
+			"Maximum number of metrics in vector.", []string{"vector"}, nil),
+		distinct: prometheus.NewDesc("dynamicvector_label_values",
+			"Number of distinct values of label key in vector.", []string{"vector", "label"}, nil),
+		empty: prometheus.NewDesc("dynamicvector_label_empty_series",
+			"Number of metrics in vector that leave label key empty.", []string{"vector", "label"}, nil),
+	}
+}
+
+// Describe implement prometheus.Collector.
+func (c *CardinalityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.series
+	ch <- c.max
+	ch <- c.distinct
+	ch <- c.empty
+}
+
+// Collect implement prometheus.Collector. Vectors with the same fully-qualified name, such as the
+// same vector returned by overlapping listers, are reported once.
+func (c *CardinalityCollector) Collect(ch chan<- prometheus.Metric) {
+	seen := make(map[string]bool)
+	for _, v := range c.vl.Vectors() {
+		if seen[v.Name()] {
+			continue
+		}
+		seen[v.Name()] = true
+
+		card, err := v.Cardinality(0)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.series, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.series, prometheus.GaugeValue, float64(card.Length), card.Name)
+		if card.MaxLength > 0 {
+			ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(card.MaxLength), card.Name)
+		}
+		for _, kc := range card.Keys {
+			ch <- prometheus.MustNewConstMetric(c.distinct, prometheus.GaugeValue, float64(kc.Distinct), card.Name, kc.Key)
+			ch <- prometheus.MustNewConstMetric(c.empty, prometheus.GaugeValue, float64(kc.Empty), card.Name, kc.Key)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_Cardinality(t *testing.T) {
+	cv := createCardinalityCounter()
+
+	c, err := cv.Cardinality(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "cardinality_vector", c.Name)
+	assert.Equal(t, 5, c.Length)
+	assert.Equal(t, 10, c.MaxLength)
+	assert.Equal(t, []dynamicvector.KeyCardinality{
+		{
+			Key:      "user",
+			Distinct: 4,
+			Empty:    1,
+			Top:      []dynamicvector.ValueCount{{Value: "a", Count: 1}, {Value: "b", Count: 1}},
+		},
+		{
+			Key:      "method",
+			Distinct: 2,
+			Empty:    0,
+			Top:      []dynamicvector.ValueCount{{Value: "GET", Count: 4}, {Value: "POST", Count: 1}},
+		},
+	}, c.Keys)
+
+	c, err = cv.Cardinality(0)
+	assert.NoError(t, err)
+	assert.Nil(t, c.Keys[0].Top)
+}
+
+func TestCardinalityHandler(t *testing.T) {
+	cv := createCardinalityCounter()
+	h := dynamicvector.NewCardinalityHandler(dynamicvector.VectorList{cv.Vector})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/cardinality?top=1", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `cardinality_vector: 5/10 series
+  user: 4 distinct, 1 empty
+    "a": 1
+  method: 2 distinct, 0 empty
+    "GET": 4
+
+`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/cardinality?name=other", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Empty(t, body)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/cardinality?top=x", nil))
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestCardinalityCollector(t *testing.T) {
+	cv := createCardinalityCounter()
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(dynamicvector.NewCardinalityCollector(dynamicvector.VectorList{cv.Vector})))
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			name := mf.GetName()
+			for _, lp := range m.Label {
+				if lp.GetName() == "label" {
+					name += "/" + lp.GetValue()
+				}
+			}
+			values[name] = m.GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"dynamicvector_series":                    5,
+		"dynamicvector_max_series":                10,
+		"dynamicvector_label_values/user":         4,
+		"dynamicvector_label_values/method":       2,
+		"dynamicvector_label_empty_series/user":   1,
+		"dynamicvector_label_empty_series/method": 0,
+	}, values)
+}
+
+func createCardinalityCounter() *dynamicvector.Counter {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:      "cardinality_vector",
+		Help:      "help",
+		MaxLength: 10,
+	})
+	for _, user := range []string{"a", "b", "c"} {
+		cv.With(prometheus.Labels{"method": "GET", "user": user}).Inc()
+	}
+	cv.With(prometheus.Labels{"method": "POST", "user": "d"}).Inc()
+	cv.With(prometheus.Labels{"method": "GET"}).Inc()
+
+	return cv
+}
+
+func TestCardinalityCollector_Duplicate(t *testing.T) {
+	cv := createCardinalityCounter()
+	reg := prometheus.NewPedanticRegistry()
+	vl := dynamicvector.VectorList{cv.Vector, cv.Vector}
+	assert.NoError(t, reg.Register(dynamicvector.NewCardinalityCollector(vl)))
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() == "dynamicvector_series" {
+			assert.Equal(t, 1, len(mf.Metric))
+		}
+	}
+}
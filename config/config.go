@@ -0,0 +1,303 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package config define dynamic vectors in JSON or YAML file. Loader create and register the
+// vectors, and re-apply the file when it change while keeping existing metrics.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Config is content of config file. Field names in YAML are the same as in JSON.
+type Config struct {
+	Vectors []VectorConfig `json:"vectors"`
+}
+
+// VectorConfig define a vector.
+type VectorConfig struct {
+	// Namespace, Subsystem and Name are components of fully-qualified name. Name is mandatory and
+	// it is used to look up the vector from Loader.
+	Namespace string `json:"namespace"`
+	Subsystem string `json:"subsystem"`
+	Name      string `json:"name"`
+
+	// Type is "counter", "gauge" or "histogram". Mandatory!
+	Type string `json:"type"`
+
+	// Help of the vector. Mandatory!
+	Help string `json:"help"`
+
+	ConstLabels prometheus.Labels `json:"const_labels"`
+
+	// Buckets for histogram, sorted without duplicate. Default is prometheus.DefBuckets.
+	Buckets []float64 `json:"buckets"`
+
+	// Expire is a duration such as "5m". Empty mean never expire.
+	Expire Duration `json:"expire"`
+
+	MaxLength int `json:"max_length"`
+
+	// Labels is label keys that are allowed after relabeling. Empty mean any label is allowed. Its order
+	// is the order of values in WithLabelValues. It is LabelNames of the vector, which keep the
+	// labels it is created with when Labels change on reload.
+	Labels []string `json:"labels"`
+
+	// Relabel rules are applied in order to labels of every With call.
+	Relabel []RelabelConfig `json:"relabel"`
+
+	relabel *relabeler // compiled by Parse.
+}
+
+// Relabel actions.
+const (
+	// ActionReplace set target label to replacement if source label value match regex.
+	ActionReplace = "replace"
+
+	// ActionLabelDrop delete every label whose name match regex.
+	ActionLabelDrop = "labeldrop"
+)
+
+// RelabelConfig is a rule to rewrite labels.
+type RelabelConfig struct {
+	// Action is ActionReplace or ActionLabelDrop. Default is ActionReplace.
+	Action string `json:"action"`
+
+	// SourceLabel is label whose value is matched against Regex. Only for ActionReplace.
+	SourceLabel string `json:"source_label"`
+
+	// Regex is anchored at both ends. Default is "(.*)".
+	Regex string `json:"regex"`
+
+	// TargetLabel is set with expanded Replacement. Default is SourceLabel. Only for ActionReplace.
+	TargetLabel string `json:"target_label"`
+
+	// Replacement may refer to capture groups of Regex. Default is "$1". Only for ActionReplace.
+	Replacement *string `json:"replacement"`
+}
+
+// Duration is time.Duration that is written as string such as "1m30s" in JSON and YAML.
+type Duration time.Duration
+
+// UnmarshalJSON implement json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// MarshalJSON implement json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Parse parse and validate config. Config that start with '{' is JSON, otherwise it is YAML. Only
+// block and single line flow collections, plain and quoted scalars, and comments are supported
+// in YAML.
+func Parse(b []byte) (*Config, error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] != '{' {
+		var err error
+		if b, err = yamlToJSON(b); err != nil {
+			return nil, fmt.Errorf("config: %s", err)
+		}
+	}
+
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("config: %s", err)
+	}
+
+	names := make(map[string]bool)
+	for i, vc := range c.Vectors {
+		if vc.Name == "" {
+			return nil, fmt.Errorf("config: vector without name")
+		}
+		if names[vc.Name] {
+			return nil, fmt.Errorf("config: duplicate vector %s", vc.Name)
+		}
+		names[vc.Name] = true
+
+		if vc.Help == "" {
+			return nil, fmt.Errorf("config: vector %s has no help", vc.Name)
+		}
+		switch vc.Type {
+		case "counter", "gauge", "histogram":
+		default:
+			return nil, fmt.Errorf("config: vector %s has invalid type %q", vc.Name, vc.Type)
+		}
+		for j := 1; j < len(vc.Buckets); j++ {
+			if vc.Buckets[j] <= vc.Buckets[j-1] {
+				return nil, fmt.Errorf("config: vector %s buckets are not sorted or have duplicate", vc.Name)
+			}
+		}
+
+		r, err := newRelabeler(vc)
+		if err != nil {
+			return nil, fmt.Errorf("config: vector %s: %s", vc.Name, err)
+		}
+		c.Vectors[i].relabel = r
+	}
+
+	return &c, nil
+}
+
+// LoadFile read and parse config file.
+func LoadFile(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(b)
+}
+
+// opts return dynamicvector options for this vector.
+func (vc VectorConfig) opts() dynamicvector.Opts {
+	opts := dynamicvector.Opts{
+		Namespace:   vc.Namespace,
+		Subsystem:   vc.Subsystem,
+		Name:        vc.Name,
+		Help:        vc.Help,
+		ConstLabels: vc.ConstLabels,
+		LabelNames:  vc.Labels,
+		Buckets:     vc.Buckets,
+		Expire:      time.Duration(vc.Expire),
+		MaxLength:   vc.MaxLength,
+		Unchecked:   true,
+	}
+	if vc.Type == "histogram" && opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+
+	return opts
+}
+
+type relabelRule struct {
+	action      string
+	source      string
+	regex       *regexp.Regexp
+	target      string
+	replacement string
+}
+
+// relabeler apply label schema and relabel rules of a vector.
+type relabeler struct {
+	rules  []relabelRule
+	schema map[string]bool
+	names  []string // label keys in schema, in order.
+}
+
+func newRelabeler(vc VectorConfig) (*relabeler, error) {
+	r := &relabeler{names: vc.Labels}
+	if len(vc.Labels) > 0 {
+		r.schema = make(map[string]bool)
+		for _, name := range vc.Labels {
+			r.schema[name] = true
+		}
+	}
+
+	for i, rc := range vc.Relabel {
+		rule := relabelRule{
+			action:      rc.Action,
+			source:      rc.SourceLabel,
+			target:      rc.TargetLabel,
+			replacement: "$1",
+		}
+		if rule.action == "" {
+			rule.action = ActionReplace
+		}
+		if rule.target == "" {
+			rule.target = rule.source
+		}
+		if rc.Replacement != nil {
+			rule.replacement = *rc.Replacement
+		}
+
+		expr := rc.Regex
+		if expr == "" {
+			expr = "(.*)"
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("relabel %d: %s", i, err)
+		}
+		rule.regex = re
+
+		switch rule.action {
+		case ActionReplace:
+			if rule.source == "" {
+				return nil, fmt.Errorf("relabel %d: replace needs source_label", i)
+			}
+		case ActionLabelDrop:
+		default:
+			return nil, fmt.Errorf("relabel %d: invalid action %q", i, rule.action)
+		}
+
+		r.rules = append(r.rules, rule)
+	}
+
+	return r, nil
+}
+
+// apply return relabeled copy of labels. It return error if result has label outside schema.
+func (r *relabeler) apply(labels prometheus.Labels) (prometheus.Labels, error) {
+	lbl := make(prometheus.Labels, len(labels))
+	for k, v := range labels {
+		lbl[k] = v
+	}
+
+	for _, rule := range r.rules {
+		switch rule.action {
+		case ActionReplace:
+			value := lbl[rule.source]
+			match := rule.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			res := string(rule.regex.ExpandString(nil, rule.replacement, value, match))
+			if res == "" {
+				delete(lbl, rule.target)
+			} else {
+				lbl[rule.target] = res
+			}
+		case ActionLabelDrop:
+			for name := range lbl {
+				if rule.regex.MatchString(name) {
+					delete(lbl, name)
+				}
+			}
+		}
+	}
+
+	if r.schema != nil {
+		for name := range lbl {
+			if !r.schema[name] {
+				return nil, fmt.Errorf("label %s is not allowed", name)
+			}
+		}
+	}
+
+	return lbl, nil
+}
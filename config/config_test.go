@@ -0,0 +1,101 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	c, err := config.Parse([]byte(`{"vectors": [{
+		"name": "http_requests_total",
+		"type": "counter",
+		"help": "Number of HTTP requests.",
+		"const_labels": {"app": "web"},
+		"expire": "5m",
+		"max_length": 100,
+		"labels": ["method", "code"],
+		"relabel": [{"source_label": "code", "regex": "(\\d)..", "replacement": "${1}xx"}]
+	}]}`))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(c.Vectors)) {
+		vc := c.Vectors[0]
+		assert.Equal(t, "http_requests_total", vc.Name)
+		assert.Equal(t, prometheus.Labels{"app": "web"}, vc.ConstLabels)
+		assert.Equal(t, config.Duration(5*time.Minute), vc.Expire)
+		assert.Equal(t, 100, vc.MaxLength)
+		assert.Equal(t, []string{"method", "code"}, vc.Labels)
+		assert.Equal(t, "code", vc.Relabel[0].SourceLabel)
+	}
+}
+
+func TestParse_YAML(t *testing.T) {
+	c, err := config.Parse([]byte(`# vectors of web app
+vectors:
+- name: http_requests_total
+  type: counter
+  help: Number of HTTP requests, by method and code.
+  const_labels: {app: web}
+  expire: 5m
+  max_length: 100
+  labels: [method, code]
+  relabel:
+    - source_label: code
+      regex: '(\d)..' # 2xx, 3xx, ...
+      replacement: "${1}xx"
+    - action: labeldrop
+      regex: debug_.*
+- name: latency_seconds
+  type: histogram
+  help: Latency.
+  buckets:
+  - 0.1
+  - 1
+`))
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(c.Vectors)) {
+		vc := c.Vectors[0]
+		assert.Equal(t, "http_requests_total", vc.Name)
+		assert.Equal(t, "Number of HTTP requests, by method and code.", vc.Help)
+		assert.Equal(t, prometheus.Labels{"app": "web"}, vc.ConstLabels)
+		assert.Equal(t, config.Duration(5*time.Minute), vc.Expire)
+		assert.Equal(t, 100, vc.MaxLength)
+		assert.Equal(t, []string{"method", "code"}, vc.Labels)
+		if assert.Equal(t, 2, len(vc.Relabel)) {
+			assert.Equal(t, `(\d)..`, vc.Relabel[0].Regex)
+			assert.Equal(t, "${1}xx", *vc.Relabel[0].Replacement)
+			assert.Equal(t, config.ActionLabelDrop, vc.Relabel[1].Action)
+		}
+		assert.Equal(t, []float64{0.1, 1}, c.Vectors[1].Buckets)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, s := range []string{
+		`{"vectors": [{"type": "counter", "help": "h"}]}`,
+		`{"vectors": [{"name": "a", "type": "counter"}]}`,
+		`{"vectors": [{"name": "a", "type": "summary", "help": "h"}]}`,
+		`{"vectors": [{"name": "a", "type": "counter", "help": "h"}, {"name": "a", "type": "gauge", "help": "h"}]}`,
+		`{"vectors": [{"name": "a", "type": "counter", "help": "h", "expire": "soon"}]}`,
+		`{"vectors": [{"name": "a", "type": "counter", "help": "h", "relabel": [{"source_label": "a", "regex": "("}]}]}`,
+		`{"vectors": [{"name": "a", "type": "counter", "help": "h", "relabel": [{"regex": "a"}]}]}`,
+		`{"vectors": [{"name": "a", "type": "counter", "help": "h", "relabel": [{"action": "keep", "source_label": "a"}]}]}`,
+		`{"vectors": [{"name": "a", "type": "histogram", "help": "h", "buckets": [1, 0.5]}]}`,
+		`{"vectors": [`,
+		"vectors:\n- name: a\n  type: [counter\n",
+		"vectors:\n- name: &a a\n",
+		"vectors:\n  - name: a\n     type: counter\n",
+		"vectors:\n- name: a\n  name: b\n",
+	} {
+		_, err := config.Parse([]byte(s))
+		assert.Error(t, err, s)
+	}
+}
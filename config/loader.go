@@ -0,0 +1,301 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Loader create vectors defined in config file and register them. It re-apply the file on Reload,
+// keeping existing metrics of vectors that are still defined.
+type Loader struct {
+	// ErrorHandler is called when Run fail to reload. Nil means error is ignored.
+	ErrorHandler func(error)
+
+	path string
+	reg  prometheus.Registerer
+
+	mtx     sync.RWMutex
+	entries map[string]*entry
+	modTime time.Time
+}
+
+type entry struct {
+	typ       string
+	vec       *dynamicvector.Vector
+	collector prometheus.Collector
+	relabel   *relabelHolder
+
+	counter   *Counter
+	gauge     *Gauge
+	histogram *Histogram
+}
+
+// relabelHolder hold relabeler that can be replaced on reload.
+type relabelHolder struct {
+	mtx sync.RWMutex
+	r   *relabeler
+}
+
+func (h *relabelHolder) get() *relabeler {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	return h.r
+}
+
+func (h *relabelHolder) set(r *relabeler) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.r = r
+}
+
+// NewLoader will create new Loader and load config file at path. Vectors are registered to reg.
+func NewLoader(path string, reg prometheus.Registerer) (*Loader, error) {
+	l := &Loader{
+		path:    path,
+		reg:     reg,
+		entries: make(map[string]*entry),
+	}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Counter return counter vector with the name. It return nil if the name is not defined as counter.
+func (l *Loader) Counter(name string) *Counter {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if e, ok := l.entries[name]; ok {
+		return e.counter
+	}
+	return nil
+}
+
+// Gauge return gauge vector with the name. It return nil if the name is not defined as gauge.
+func (l *Loader) Gauge(name string) *Gauge {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if e, ok := l.entries[name]; ok {
+		return e.gauge
+	}
+	return nil
+}
+
+// Histogram return histogram vector with the name. It return nil if the name is not defined as
+// histogram.
+func (l *Loader) Histogram(name string) *Histogram {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if e, ok := l.entries[name]; ok {
+		return e.histogram
+	}
+	return nil
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (l *Loader) Vectors() []*dynamicvector.Vector {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	vectors := make([]*dynamicvector.Vector, 0, len(l.entries))
+	for _, e := range l.entries {
+		vectors = append(vectors, e.vec)
+	}
+	return vectors
+}
+
+// Reload read config file and apply it. Existing vector keep its metrics while its options and
+// relabel rules are updated. Vector that is removed from config is unregistered. Nothing is changed
+// if config file is invalid, a vector change its type, namespace or subsystem, or a new vector can
+// not be registered.
+func (l *Loader) Reload() error {
+	st, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	c, err := LoadFile(l.path)
+	if err != nil {
+		return err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// check and build everything first, so config is applied completely or not at all.
+	type update struct {
+		e    *entry
+		opts dynamicvector.Opts
+		r    *relabeler
+	}
+	var updates []update
+	added := make(map[string]*entry)
+	defined := make(map[string]bool)
+	for _, vc := range c.Vectors {
+		defined[vc.Name] = true
+
+		e, ok := l.entries[vc.Name]
+		if !ok {
+			added[vc.Name] = newEntry(vc)
+			continue
+		}
+		if err := e.check(vc); err != nil {
+			return fmt.Errorf("config: vector %s %s", vc.Name, err)
+		}
+		updates = append(updates, update{e: e, opts: vc.opts(), r: vc.relabel})
+	}
+
+	removed := make(map[string]*entry)
+	for name, e := range l.entries {
+		if !defined[name] {
+			removed[name] = e
+		}
+	}
+
+	// removed vectors are unregistered first, so new vectors can take their names.
+	if l.reg != nil {
+		for _, e := range removed {
+			l.reg.Unregister(e.collector)
+		}
+
+		var registered []*entry
+		for name, e := range added {
+			if err := l.reg.Register(e.collector); err != nil {
+				for _, e := range registered {
+					l.reg.Unregister(e.collector)
+				}
+				for _, e := range removed {
+					l.reg.Register(e.collector)
+				}
+				return fmt.Errorf("config: vector %s: %s", name, err)
+			}
+			registered = append(registered, e)
+		}
+	}
+
+	// Parse validate Buckets and check reject changes of fixed options, so Update only fail when
+	// the vector is updated outside Loader. Its error is returned after the rest is applied.
+	var errs []string
+	for _, u := range updates {
+		err := u.e.vec.Update(func(o *dynamicvector.Opts) {
+			o.Help = u.opts.Help
+			o.ConstLabels = u.opts.ConstLabels
+			o.Buckets = u.opts.Buckets
+			o.Expire = u.opts.Expire
+			o.MaxLength = u.opts.MaxLength
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("vector %s: %s", u.e.vec.Name(), err))
+		}
+		u.e.relabel.set(u.r)
+	}
+	for name := range removed {
+		delete(l.entries, name)
+	}
+	for name, e := range added {
+		l.entries[name] = e
+	}
+
+	l.modTime = st.ModTime()
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Run reload config file when its modification time change, checked every interval, or when the
+// process receive SIGHUP. It stop when ctx is done.
+func (l *Loader) Run(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			l.reload()
+		case <-ticker.C:
+			st, err := os.Stat(l.path)
+			if err != nil {
+				l.handleError(err)
+				continue
+			}
+
+			l.mtx.RLock()
+			changed := !st.ModTime().Equal(l.modTime)
+			l.mtx.RUnlock()
+			if changed {
+				l.reload()
+			}
+		}
+	}
+}
+
+func (l *Loader) reload() {
+	if err := l.Reload(); err != nil {
+		l.handleError(err)
+	}
+}
+
+func (l *Loader) handleError(err error) {
+	if l.ErrorHandler != nil {
+		l.ErrorHandler(err)
+	}
+}
+
+// check return error if vector of e can not be changed to vc.
+func (e *entry) check(vc VectorConfig) error {
+	opts := e.vec.Opts()
+	switch {
+	case e.typ != vc.Type:
+		return fmt.Errorf("can not change type from %s to %s", e.typ, vc.Type)
+	case opts.Namespace != vc.Namespace:
+		return fmt.Errorf("can not change namespace from %q to %q", opts.Namespace, vc.Namespace)
+	case opts.Subsystem != vc.Subsystem:
+		return fmt.Errorf("can not change subsystem from %q to %q", opts.Subsystem, vc.Subsystem)
+	}
+	return nil
+}
+
+func newEntry(vc VectorConfig) *entry {
+	e := &entry{typ: vc.Type, relabel: &relabelHolder{r: vc.relabel}}
+	l := labeler{relabel: e.relabel}
+
+	switch vc.Type {
+	case "counter":
+		e.counter = &Counter{vec: dynamicvector.NewCounter(vc.opts()), labeler: l}
+		e.vec, e.collector = e.counter.vec.Vector, e.counter.vec
+	case "gauge":
+		e.gauge = &Gauge{vec: dynamicvector.NewGauge(vc.opts()), labeler: l}
+		e.vec, e.collector = e.gauge.vec.Vector, e.gauge.vec
+	case "histogram":
+		e.histogram = &Histogram{vec: dynamicvector.NewHistogram(vc.opts()), labeler: l}
+		e.vec, e.collector = e.histogram.vec.Vector, e.histogram.vec
+	}
+
+	return e
+}
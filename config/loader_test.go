@@ -0,0 +1,227 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package config_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `{"vectors": [
+	{
+		"name": "http_requests_total",
+		"type": "counter",
+		"help": "Number of HTTP requests.",
+		"labels": ["method", "code"],
+		"relabel": [
+			{"source_label": "code", "regex": "(\\d)..", "replacement": "${1}xx"},
+			{"action": "labeldrop", "regex": "debug_.*"}
+		]
+	},
+	{"name": "temperature", "type": "gauge", "help": "Temperature."},
+	{"name": "latency_seconds", "type": "histogram", "help": "Latency.", "buckets": [0.1, 1]}
+]}`
+
+func TestLoader(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	reg := prometheus.NewPedanticRegistry()
+	l, err := config.NewLoader(path, reg)
+	assert.NoError(t, err)
+
+	c := l.Counter("http_requests_total")
+	if assert.NotNil(t, c) {
+		c.With(prometheus.Labels{"method": "GET", "code": "404", "debug_id": "x"}).Inc()
+		c.With(prometheus.Labels{"method": "GET", "code": "400"}).Inc()
+		_, err = c.GetMetricWith(prometheus.Labels{"user": "a"})
+		assert.Error(t, err)
+
+		series, _ := c.Series()
+		if assert.Equal(t, 1, len(series)) {
+			assert.Equal(t, prometheus.Labels{"method": "GET", "code": "4xx"}, series[0].Labels)
+			assert.Equal(t, 2.0, series[0].Metric.GetCounter().GetValue())
+		}
+		assert.Equal(t, []string{"method", "code"}, c.Opts().LabelNames)
+	}
+	assert.Nil(t, l.Counter("temperature"))
+	assert.NotNil(t, l.Gauge("temperature"))
+	assert.Nil(t, l.Gauge("unknown"))
+	if h := l.Histogram("latency_seconds"); assert.NotNil(t, h) {
+		assert.Equal(t, []float64{0.1, 1}, h.Opts().Buckets)
+		h.With(prometheus.Labels{}).Observe(1)
+	}
+	assert.Equal(t, 3, len(l.Vectors()))
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(mfs))
+}
+
+func TestLoader_Reload(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	reg := prometheus.NewPedanticRegistry()
+	l, err := config.NewLoader(path, reg)
+	assert.NoError(t, err)
+
+	c := l.Counter("http_requests_total")
+	c.With(prometheus.Labels{"method": "GET", "code": "200"}).Inc()
+
+	writeFile(t, path, `{"vectors": [
+		{"name": "http_requests_total", "type": "counter", "help": "HTTP requests.", "max_length": 10, "labels": ["method", "code", "user"]},
+		{"name": "queue_length", "type": "gauge", "help": "Queue length."}
+	]}`)
+	assert.NoError(t, l.Reload())
+
+	// existing vector keep its metrics and get new options and relabel rules.
+	assert.Equal(t, c, l.Counter("http_requests_total"))
+	assert.Equal(t, 1, c.Length())
+	assert.Equal(t, "HTTP requests.", c.Opts().Help)
+	assert.Equal(t, 10, c.Opts().MaxLength)
+	_, err = c.GetMetricWith(prometheus.Labels{"user": "a", "code": "500"})
+	assert.NoError(t, err)
+	series, _ := c.Series()
+	assert.Equal(t, prometheus.Labels{"code": "500", "user": "a"}, series[1].Labels)
+
+	assert.Nil(t, l.Gauge("temperature"))
+	assert.NotNil(t, l.Gauge("queue_length"))
+	assert.Equal(t, 2, len(l.Vectors()))
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(mfs)) {
+		assert.Equal(t, "HTTP requests.", mfs[0].GetHelp())
+	}
+}
+
+func TestLoader_ReloadInvalid(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	l, err := config.NewLoader(path, nil)
+	assert.NoError(t, err)
+
+	writeFile(t, path, `{"vectors": [{"name": "temperature", "type": "counter", "help": "Temperature."}]}`)
+	assert.Error(t, l.Reload())
+	writeFile(t, path, `{"vectors": [`)
+	assert.Error(t, l.Reload())
+
+	assert.NotNil(t, l.Gauge("temperature"))
+	assert.Equal(t, 3, len(l.Vectors()))
+
+	_, err = config.NewLoader(filepath.Join(filepath.Dir(path), "missing.json"), nil)
+	assert.Error(t, err)
+}
+
+func TestLoader_ReloadAtomic(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	reg := &failingRegisterer{Registerer: prometheus.NewPedanticRegistry()}
+	l, err := config.NewLoader(path, reg)
+	assert.NoError(t, err)
+
+	// new vector can not be registered, so update of existing vector and removal are not applied.
+	reg.fail = true
+	writeFile(t, path, `{"vectors": [
+		{"name": "http_requests_total", "type": "counter", "help": "HTTP requests."},
+		{"name": "queue_length", "type": "gauge", "help": "Queue length."}
+	]}`)
+	assert.Error(t, l.Reload())
+	assert.Equal(t, "Number of HTTP requests.", l.Counter("http_requests_total").Opts().Help)
+	assert.NotNil(t, l.Gauge("temperature"))
+	assert.Nil(t, l.Gauge("queue_length"))
+	assert.Equal(t, 3, len(l.Vectors()))
+	assert.Equal(t, 3, reg.registered)
+
+	writeFile(t, path, `{"vectors": [{"namespace": "app", "name": "temperature", "type": "gauge", "help": "Temperature."}]}`)
+	assert.Error(t, l.Reload())
+	assert.Equal(t, 3, len(l.Vectors()))
+}
+
+func TestLoader_Run(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	l, err := config.NewLoader(path, nil)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Run(ctx, 10*time.Millisecond)
+
+	writeFile(t, path, `{"vectors": [{"name": "a", "type": "gauge", "help": "A."}]}`)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+	assert.True(t, waitFor(func() bool { return l.Gauge("a") != nil }))
+
+	// content change without modification time change is applied on SIGHUP.
+	st, _ := os.Stat(path)
+	writeFile(t, path, `{"vectors": [{"name": "b", "type": "gauge", "help": "B."}]}`)
+	os.Chtimes(path, st.ModTime(), st.ModTime())
+	time.Sleep(30 * time.Millisecond)
+	assert.NotNil(t, l.Gauge("a"))
+
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	assert.True(t, waitFor(func() bool { return l.Gauge("b") != nil }))
+}
+
+func waitFor(fn func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if fn() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "vectors.json")
+	writeFile(t, path, content)
+	return path
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failingRegisterer fail to register collector that is never registered before when fail is set.
+type failingRegisterer struct {
+	prometheus.Registerer
+	fail       bool
+	known      map[prometheus.Collector]bool
+	registered int
+}
+
+func (r *failingRegisterer) Register(c prometheus.Collector) error {
+	if r.fail && !r.known[c] {
+		return errors.New("fail")
+	}
+	if r.known == nil {
+		r.known = make(map[prometheus.Collector]bool)
+	}
+	r.known[c] = true
+	r.registered++
+	return r.Registerer.Register(c)
+}
+
+func (r *failingRegisterer) Unregister(c prometheus.Collector) bool {
+	r.registered--
+	return r.Registerer.Unregister(c)
+}
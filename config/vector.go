@@ -0,0 +1,529 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// labeler turn labels given to Counter, Gauge and Histogram into labels of their vector, by merging
+// curried labels and applying relabel rules and label schema.
+type labeler struct {
+	relabel *relabelHolder
+	curry   prometheus.Labels
+}
+
+func (l labeler) labels(labels prometheus.Labels) (prometheus.Labels, error) {
+	return l.apply(l.relabel.get(), labels)
+}
+
+func (l labeler) apply(r *relabeler, labels prometheus.Labels) (prometheus.Labels, error) {
+	if len(l.curry) == 0 {
+		return r.apply(labels)
+	}
+
+	merged := make(prometheus.Labels, len(l.curry)+len(labels))
+	for name, value := range labels {
+		if _, ok := l.curry[name]; ok {
+			return nil, fmt.Errorf("label %s is already curried", name)
+		}
+		merged[name] = value
+	}
+	for name, value := range l.curry {
+		merged[name] = value
+	}
+	return r.apply(merged)
+}
+
+// labelValues return labels from values ordered as Labels in config, without curried labels.
+func (l labeler) labelValues(values []string) (prometheus.Labels, error) {
+	r := l.relabel.get()
+
+	var names []string
+	for _, name := range r.names {
+		if _, ok := l.curry[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(values) != len(names) {
+		return nil, fmt.Errorf("expected %d label values but got %d", len(names), len(values))
+	}
+
+	labels := make(prometheus.Labels, len(values))
+	for i, name := range names {
+		labels[name] = values[i]
+	}
+	return l.apply(r, labels)
+}
+
+func (l labeler) structLabels(s interface{}) (prometheus.Labels, error) {
+	labels, err := dynamicvector.StructLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	return l.labels(labels)
+}
+
+// curryWith return labeler with more curried labels. Curried labels are relabeled with the rest.
+func (l labeler) curryWith(labels prometheus.Labels) (labeler, error) {
+	curry := make(prometheus.Labels, len(l.curry)+len(labels))
+	for name, value := range l.curry {
+		curry[name] = value
+	}
+	for name, value := range labels {
+		if _, ok := curry[name]; ok {
+			return l, fmt.Errorf("label %s is already curried", name)
+		}
+		curry[name] = value
+	}
+
+	return labeler{relabel: l.relabel, curry: curry}, nil
+}
+
+// Counter is a dynamicvector.Counter whose labels are relabeled and checked against label schema. Every
+// method that take labels apply relabel rules.
+type Counter struct {
+	vec     *dynamicvector.Counter
+	labeler labeler
+}
+
+// Describe implement prometheus.Collector.
+func (c *Counter) Describe(ch chan<- *prometheus.Desc) {
+	c.vec.Describe(ch)
+}
+
+// Collect implement prometheus.Collector.
+func (c *Counter) Collect(ch chan<- prometheus.Metric) {
+	c.vec.Collect(ch)
+}
+
+// Name return fully-qualified name of the counter.
+func (c *Counter) Name() string {
+	return c.vec.Name()
+}
+
+// Opts return current options of the counter.
+func (c *Counter) Opts() dynamicvector.Opts {
+	return c.vec.Opts()
+}
+
+// Length return number of metrics in the counter, including metrics of other curried views.
+func (c *Counter) Length() int {
+	return c.vec.Length()
+}
+
+// Series return relabeled labels and values of metrics in the counter, see dynamicvector.Vector.Series.
+func (c *Counter) Series(matchers ...*dynamicvector.Matcher) ([]dynamicvector.Series, error) {
+	return c.vec.Series(matchers...)
+}
+
+// GetMetricWith behave like dynamicvector.Counter.GetMetricWith after relabeling labels.
+func (c *Counter) GetMetricWith(labels prometheus.Labels) (prometheus.Counter, error) {
+	lbl, err := c.labeler.labels(labels)
+	if err != nil {
+		return nil, err
+	}
+	return c.vec.GetMetricWith(lbl)
+}
+
+// With behave like GetMetricWith except it will panic instead when there is an error.
+func (c *Counter) With(labels prometheus.Labels) prometheus.Counter {
+	m, err := c.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetMetricWithLabelValues behave like GetMetricWith with label values ordered as Labels in config.
+func (c *Counter) GetMetricWithLabelValues(values ...string) (prometheus.Counter, error) {
+	lbl, err := c.labeler.labelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return c.vec.GetMetricWith(lbl)
+}
+
+// WithLabelValues behave like GetMetricWithLabelValues except it will panic instead when there is an error.
+func (c *Counter) WithLabelValues(values ...string) prometheus.Counter {
+	m, err := c.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetMetricWithStruct behave like GetMetricWith with labels from dynamicvector.StructLabels.
+func (c *Counter) GetMetricWithStruct(s interface{}) (prometheus.Counter, error) {
+	lbl, err := c.labeler.structLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	return c.vec.GetMetricWith(lbl)
+}
+
+// WithStruct behave like GetMetricWithStruct except it will panic instead when there is an error.
+func (c *Counter) WithStruct(s interface{}) prometheus.Counter {
+	m, err := c.GetMetricWithStruct(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Delete behave like dynamicvector.Counter.Delete after relabeling labels.
+func (c *Counter) Delete(labels prometheus.Labels) bool {
+	lbl, err := c.labeler.labels(labels)
+	if err != nil {
+		return false
+	}
+	return c.vec.Delete(lbl)
+}
+
+// Handle return handle of counter with relabeled labels. Labels are relabeled once, it will panic
+// when there is an error.
+func (c *Counter) Handle(labels prometheus.Labels) *dynamicvector.CounterHandle {
+	lbl, err := c.labeler.labels(labels)
+	if err != nil {
+		panic(err)
+	}
+	return c.vec.Handle(lbl)
+}
+
+// CurryWith return a view of this counter with labels bound. Bound labels are relabeled together
+// with labels of every call.
+func (c *Counter) CurryWith(labels prometheus.Labels) (*Counter, error) {
+	l, err := c.labeler.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &Counter{vec: c.vec, labeler: l}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (c *Counter) MustCurryWith(labels prometheus.Labels) *Counter {
+	vec, err := c.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return vec
+}
+
+// Gauge is a dynamicvector.Gauge whose labels are relabeled and checked against label schema. Every
+// method that take labels apply relabel rules.
+type Gauge struct {
+	vec     *dynamicvector.Gauge
+	labeler labeler
+}
+
+// Describe implement prometheus.Collector.
+func (g *Gauge) Describe(ch chan<- *prometheus.Desc) {
+	g.vec.Describe(ch)
+}
+
+// Collect implement prometheus.Collector.
+func (g *Gauge) Collect(ch chan<- prometheus.Metric) {
+	g.vec.Collect(ch)
+}
+
+// Name return fully-qualified name of the gauge.
+func (g *Gauge) Name() string {
+	return g.vec.Name()
+}
+
+// Opts return current options of the gauge.
+func (g *Gauge) Opts() dynamicvector.Opts {
+	return g.vec.Opts()
+}
+
+// Length return number of metrics in the gauge, including metrics of other curried views.
+func (g *Gauge) Length() int {
+	return g.vec.Length()
+}
+
+// Series return relabeled labels and values of metrics in the gauge, see dynamicvector.Vector.Series.
+func (g *Gauge) Series(matchers ...*dynamicvector.Matcher) ([]dynamicvector.Series, error) {
+	return g.vec.Series(matchers...)
+}
+
+// GetMetricWith behave like dynamicvector.Gauge.GetMetricWith after relabeling labels.
+func (g *Gauge) GetMetricWith(labels prometheus.Labels) (prometheus.Gauge, error) {
+	lbl, err := g.labeler.labels(labels)
+	if err != nil {
+		return nil, err
+	}
+	return g.vec.GetMetricWith(lbl)
+}
+
+// With behave like GetMetricWith except it will panic instead when there is an error.
+func (g *Gauge) With(labels prometheus.Labels) prometheus.Gauge {
+	m, err := g.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetMetricWithLabelValues behave like GetMetricWith with label values ordered as Labels in config.
+func (g *Gauge) GetMetricWithLabelValues(values ...string) (prometheus.Gauge, error) {
+	lbl, err := g.labeler.labelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return g.vec.GetMetricWith(lbl)
+}
+
+// WithLabelValues behave like GetMetricWithLabelValues except it will panic instead when there is an error.
+func (g *Gauge) WithLabelValues(values ...string) prometheus.Gauge {
+	m, err := g.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetMetricWithStruct behave like GetMetricWith with labels from dynamicvector.StructLabels.
+func (g *Gauge) GetMetricWithStruct(s interface{}) (prometheus.Gauge, error) {
+	lbl, err := g.labeler.structLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	return g.vec.GetMetricWith(lbl)
+}
+
+// WithStruct behave like GetMetricWithStruct except it will panic instead when there is an error.
+func (g *Gauge) WithStruct(s interface{}) prometheus.Gauge {
+	m, err := g.GetMetricWithStruct(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Delete behave like dynamicvector.Gauge.Delete after relabeling labels.
+func (g *Gauge) Delete(labels prometheus.Labels) bool {
+	lbl, err := g.labeler.labels(labels)
+	if err != nil {
+		return false
+	}
+	return g.vec.Delete(lbl)
+}
+
+// Handle return handle of gauge with relabeled labels. Labels are relabeled once, it will panic
+// when there is an error.
+func (g *Gauge) Handle(labels prometheus.Labels) *dynamicvector.GaugeHandle {
+	lbl, err := g.labeler.labels(labels)
+	if err != nil {
+		panic(err)
+	}
+	return g.vec.Handle(lbl)
+}
+
+// CurryWith return a view of this gauge with labels bound. Bound labels are relabeled together
+// with labels of every call.
+func (g *Gauge) CurryWith(labels prometheus.Labels) (*Gauge, error) {
+	l, err := g.labeler.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &Gauge{vec: g.vec, labeler: l}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (g *Gauge) MustCurryWith(labels prometheus.Labels) *Gauge {
+	vec, err := g.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return vec
+}
+
+// Histogram is a dynamicvector.Histogram whose labels are relabeled and checked against label schema. Every
+// method that take labels apply relabel rules.
+type Histogram struct {
+	vec     *dynamicvector.Histogram
+	labeler labeler
+}
+
+// Describe implement prometheus.Collector.
+func (h *Histogram) Describe(ch chan<- *prometheus.Desc) {
+	h.vec.Describe(ch)
+}
+
+// Collect implement prometheus.Collector.
+func (h *Histogram) Collect(ch chan<- prometheus.Metric) {
+	h.vec.Collect(ch)
+}
+
+// Name return fully-qualified name of the histogram.
+func (h *Histogram) Name() string {
+	return h.vec.Name()
+}
+
+// Opts return current options of the histogram.
+func (h *Histogram) Opts() dynamicvector.Opts {
+	return h.vec.Opts()
+}
+
+// Length return number of metrics in the histogram, including metrics of other curried views.
+func (h *Histogram) Length() int {
+	return h.vec.Length()
+}
+
+// Series return relabeled labels and values of metrics in the histogram, see dynamicvector.Vector.Series.
+func (h *Histogram) Series(matchers ...*dynamicvector.Matcher) ([]dynamicvector.Series, error) {
+	return h.vec.Series(matchers...)
+}
+
+// GetMetricWith behave like dynamicvector.Histogram.GetMetricWith after relabeling labels.
+func (h *Histogram) GetMetricWith(labels prometheus.Labels) (prometheus.Histogram, error) {
+	lbl, err := h.labeler.labels(labels)
+	if err != nil {
+		return nil, err
+	}
+	return h.vec.GetMetricWith(lbl)
+}
+
+// With behave like GetMetricWith except it will panic instead when there is an error.
+func (h *Histogram) With(labels prometheus.Labels) prometheus.Histogram {
+	m, err := h.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetMetricWithLabelValues behave like GetMetricWith with label values ordered as Labels in config.
+func (h *Histogram) GetMetricWithLabelValues(values ...string) (prometheus.Histogram, error) {
+	lbl, err := h.labeler.labelValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return h.vec.GetMetricWith(lbl)
+}
+
+// WithLabelValues behave like GetMetricWithLabelValues except it will panic instead when there is an error.
+func (h *Histogram) WithLabelValues(values ...string) prometheus.Histogram {
+	m, err := h.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// GetMetricWithStruct behave like GetMetricWith with labels from dynamicvector.StructLabels.
+func (h *Histogram) GetMetricWithStruct(s interface{}) (prometheus.Histogram, error) {
+	lbl, err := h.labeler.structLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	return h.vec.GetMetricWith(lbl)
+}
+
+// WithStruct behave like GetMetricWithStruct except it will panic instead when there is an error.
+func (h *Histogram) WithStruct(s interface{}) prometheus.Histogram {
+	m, err := h.GetMetricWithStruct(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Delete behave like dynamicvector.Histogram.Delete after relabeling labels.
+func (h *Histogram) Delete(labels prometheus.Labels) bool {
+	lbl, err := h.labeler.labels(labels)
+	if err != nil {
+		return false
+	}
+	return h.vec.Delete(lbl)
+}
+
+// Handle return handle of histogram with relabeled labels. Labels are relabeled once, it will panic
+// when there is an error.
+func (h *Histogram) Handle(labels prometheus.Labels) *dynamicvector.HistogramHandle {
+	lbl, err := h.labeler.labels(labels)
+	if err != nil {
+		panic(err)
+	}
+	return h.vec.Handle(lbl)
+}
+
+// CurryWith return a view of this histogram with labels bound. Bound labels are relabeled together
+// with labels of every call.
+func (h *Histogram) CurryWith(labels prometheus.Labels) (*Histogram, error) {
+	l, err := h.labeler.curryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &Histogram{vec: h.vec, labeler: l}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (h *Histogram) MustCurryWith(labels prometheus.Labels) *Histogram {
+	vec, err := h.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return vec
+}
+
+// ObserverVec return this histogram as prometheus.ObserverVec, so it can be used with promhttp
+// instrumentation.
+func (h *Histogram) ObserverVec() prometheus.ObserverVec {
+	return observerVec{h: h}
+}
+
+// observerVec adapt Histogram to prometheus.ObserverVec.
+type observerVec struct {
+	h *Histogram
+}
+
+func (o observerVec) Describe(ch chan<- *prometheus.Desc) {
+	o.h.Describe(ch)
+}
+
+func (o observerVec) Collect(ch chan<- prometheus.Metric) {
+	o.h.Collect(ch)
+}
+
+func (o observerVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	m, err := o.h.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (o observerVec) GetMetricWithLabelValues(values ...string) (prometheus.Observer, error) {
+	m, err := o.h.GetMetricWithLabelValues(values...)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (o observerVec) With(labels prometheus.Labels) prometheus.Observer {
+	return o.h.With(labels)
+}
+
+func (o observerVec) WithLabelValues(values ...string) prometheus.Observer {
+	return o.h.WithLabelValues(values...)
+}
+
+func (o observerVec) CurryWith(labels prometheus.Labels) (prometheus.ObserverVec, error) {
+	h, err := o.h.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return h.ObserverVec(), nil
+}
+
+func (o observerVec) MustCurryWith(labels prometheus.Labels) prometheus.ObserverVec {
+	return o.h.MustCurryWith(labels).ObserverVec()
+}
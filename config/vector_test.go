@@ -0,0 +1,65 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter_Relabel(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	l, err := config.NewLoader(path, nil)
+	assert.NoError(t, err)
+	c := l.Counter("http_requests_total")
+
+	c.WithLabelValues("GET", "404").Inc()
+	c.WithStruct(struct {
+		Method string `label:"method"`
+		Code   int    `label:"code"`
+	}{"GET", 403}).Inc()
+	c.Handle(prometheus.Labels{"method": "GET", "code": "400"}).Inc()
+	get := c.MustCurryWith(prometheus.Labels{"method": "GET"})
+	get.WithLabelValues("401").Inc()
+	get.With(prometheus.Labels{"code": "402", "debug_id": "x"}).Inc()
+
+	series, _ := c.Series()
+	if assert.Equal(t, 1, len(series)) {
+		assert.Equal(t, prometheus.Labels{"method": "GET", "code": "4xx"}, series[0].Labels)
+		assert.Equal(t, 5.0, series[0].Metric.GetCounter().GetValue())
+	}
+
+	// labels outside schema are rejected by every method.
+	_, err = c.GetMetricWithStruct(struct {
+		User string `label:"user"`
+	}{"a"})
+	assert.Error(t, err)
+	_, err = c.GetMetricWithLabelValues("GET")
+	assert.Error(t, err)
+	_, err = get.GetMetricWith(prometheus.Labels{"method": "POST"})
+	assert.Error(t, err)
+	assert.Panics(t, func() { c.Handle(prometheus.Labels{"user": "a"}) })
+
+	assert.True(t, get.Delete(prometheus.Labels{"code": "404"}))
+	assert.Equal(t, 0, c.Length())
+}
+
+func TestHistogram_ObserverVec(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+	l, err := config.NewLoader(path, nil)
+	assert.NoError(t, err)
+	h := l.Histogram("latency_seconds")
+
+	h.ObserverVec().MustCurryWith(prometheus.Labels{"path": "/"}).With(prometheus.Labels{}).Observe(1)
+	assert.Equal(t, 1, h.Length())
+}
@@ -0,0 +1,412 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlToJSON convert YAML document into JSON, so it can be decoded with json tags of Config. Only
+// the subset that is needed by config file is supported: block mappings and sequences, flow
+// sequences and mappings on a single line, quoted and plain scalars, and comments. Anchors, tags,
+// block scalars and multiple documents are rejected.
+func yamlToJSON(b []byte) ([]byte, error) {
+	lines, err := yamlLines(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &yamlParser{lines: lines}
+	var v interface{}
+	if len(lines) > 0 {
+		if v, err = p.block(lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) {
+			return nil, p.errorf("unexpected indentation")
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+type yamlLine struct {
+	num    int // line number, starting from 1.
+	indent int
+	text   string // without indentation and comment.
+}
+
+// yamlLines split s into lines, skipping empty lines, comments and document start.
+func yamlLines(s string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(s, "\n") {
+		raw = strings.TrimRight(stripComment(raw), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		switch {
+		case text == "":
+			continue
+		case strings.HasPrefix(text, "\t"):
+			return nil, fmt.Errorf("yaml: line %d: tab is not allowed in indentation", i+1)
+		case text == "---" && len(lines) == 0:
+			continue
+		case text == "---" || text == "...":
+			return nil, fmt.Errorf("yaml: line %d: multiple documents are not supported", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: text})
+	}
+
+	return lines, nil
+}
+
+// stripComment remove comment that start with '#' at the beginning or after whitespace, outside
+// of quoted string.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("yaml: line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block parse a mapping, sequence or scalar whose lines are indented by indent.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	switch {
+	case isSeqItem(line.text):
+		return p.sequence(indent)
+	case mappingKey(line.text) >= 0:
+		return p.mapping(indent)
+	}
+
+	p.pos++
+	return parseFlow(line.text, line.num)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	seq := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isSeqItem(line.text) {
+			break
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, item)
+			continue
+		}
+
+		// "- key: value" start a mapping whose keys are aligned with key.
+		p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+		item, err := p.block(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item)
+	}
+
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return seq, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || isSeqItem(line.text) {
+			break
+		}
+
+		i := mappingKey(line.text)
+		if i < 0 {
+			return nil, p.errorf("expected key: value")
+		}
+		key, err := parseScalar(strings.TrimSpace(line.text[:i]), line.num)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprint(key)
+		if _, ok := m[name]; ok {
+			return nil, p.errorf("duplicate key %s", name)
+		}
+
+		p.pos++
+		value := strings.TrimSpace(line.text[i+1:])
+		if value != "" {
+			m[name], err = parseFlow(value, line.num)
+		} else if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text) {
+			// sequence may have the same indentation as its key.
+			m[name], err = p.sequence(indent)
+		} else {
+			m[name], err = p.child(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return m, nil
+}
+
+// child parse block that is indented more than parent, or return nil if there is none.
+func (p *yamlParser) child(parent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return nil, nil
+	}
+
+	return p.block(p.lines[p.pos].indent)
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// mappingKey return index of ':' that end the key in text, or -1 if text is not a mapping entry.
+func mappingKey(text string) int {
+	if text[0] == '[' || text[0] == '{' {
+		return -1
+	}
+
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseFlow parse value on a single line, which may be a flow sequence or mapping. Plain scalar
+// take the whole line.
+func parseFlow(s string, num int) (interface{}, error) {
+	if !strings.ContainsRune("[{\"'", rune(s[0])) {
+		return parseScalar(s, num)
+	}
+
+	f := &flowParser{s: s, num: num}
+	v, err := f.value()
+	if err != nil {
+		return nil, err
+	}
+	if f.skipSpace(); f.i < len(f.s) {
+		return nil, f.errorf("unexpected %q", f.s[f.i:])
+	}
+
+	return v, nil
+}
+
+type flowParser struct {
+	s   string
+	i   int
+	num int
+}
+
+func (f *flowParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("yaml: line %d: %s", f.num, fmt.Sprintf(format, args...))
+}
+
+func (f *flowParser) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *flowParser) value() (interface{}, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, nil
+	}
+
+	switch f.s[f.i] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		return f.quoted()
+	}
+
+	// plain scalar end at ',', ']' or '}' only inside flow collection, which is checked by caller.
+	start := f.i
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+		f.i++
+	}
+	return parseScalar(strings.TrimSpace(f.s[start:f.i]), f.num)
+}
+
+func (f *flowParser) quoted() (interface{}, error) {
+	start := f.i
+	quote := f.s[f.i]
+	for f.i++; f.i < len(f.s); f.i++ {
+		switch {
+		case quote == '"' && f.s[f.i] == '\\':
+			f.i++
+		case f.s[f.i] == quote && quote == '\'' && f.i+1 < len(f.s) && f.s[f.i+1] == '\'':
+			f.i++
+		case f.s[f.i] == quote:
+			f.i++
+			return parseScalar(f.s[start:f.i], f.num)
+		}
+	}
+
+	return nil, f.errorf("unterminated string")
+}
+
+func (f *flowParser) sequence() (interface{}, error) {
+	seq := []interface{}{}
+	f.i++
+	for {
+		if f.skipSpace(); f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return seq, nil
+		}
+
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, v)
+
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flowParser) mapping() (interface{}, error) {
+	m := make(map[string]interface{})
+	f.i++
+	for {
+		if f.skipSpace(); f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+
+		i := strings.IndexByte(f.s[f.i:], ':')
+		if i < 0 {
+			return nil, f.errorf("expected key: value")
+		}
+		key, err := parseScalar(strings.TrimSpace(f.s[f.i:f.i+i]), f.num)
+		if err != nil {
+			return nil, err
+		}
+		f.i += i + 1
+
+		if m[fmt.Sprint(key)], err = f.value(); err != nil {
+			return nil, err
+		}
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator skip ',' between items, leaving end for the caller.
+func (f *flowParser) separator(end byte) error {
+	f.skipSpace()
+	switch {
+	case f.i >= len(f.s):
+		return f.errorf("flow collection must end on the same line")
+	case f.s[f.i] == ',':
+		f.i++
+	case f.s[f.i] != end:
+		return f.errorf("expected , or %c", end)
+	}
+
+	return nil
+}
+
+// parseScalar resolve quoted or plain scalar into string, number, bool or nil.
+func parseScalar(s string, num int) (interface{}, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	switch s[0] {
+	case '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid string %s", num, s)
+		}
+		return v, nil
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("yaml: line %d: invalid string %s", num, s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case '&', '*', '!', '|', '>', '@', '`':
+		return nil, fmt.Errorf("yaml: line %d: %q is not supported", num, s[:1])
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXnN_") {
+		return n, nil
+	}
+
+	return s, nil
+}
@@ -8,6 +8,7 @@ package dynamicvector
 import (
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -39,28 +40,76 @@ func (c *Counter) With(labels prometheus.Labels) prometheus.Counter {
 	return c.Vector.With(labels).(prometheus.Counter)
 }
 
+// GetMetricWithLabelValues is a syntatic sugar for Vector.GetMetricWithLabelValues
+func (c *Counter) GetMetricWithLabelValues(values ...string) (prometheus.Counter, error) {
+	metric, err := c.Vector.GetMetricWithLabelValues(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.(prometheus.Counter), nil
+}
+
+// WithLabelValues is a syntatic sugar for Vector.WithLabelValues
+func (c *Counter) WithLabelValues(values ...string) prometheus.Counter {
+	return c.Vector.WithLabelValues(values...).(prometheus.Counter)
+}
+
+// GetMetricWithStruct is a syntatic sugar for Vector.GetMetricWithStruct
+func (c *Counter) GetMetricWithStruct(s interface{}) (prometheus.Counter, error) {
+	metric, err := c.Vector.GetMetricWithStruct(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.(prometheus.Counter), nil
+}
+
+// WithStruct is a syntatic sugar for Vector.WithStruct
+func (c *Counter) WithStruct(s interface{}) prometheus.Counter {
+	return c.Vector.WithStruct(s).(prometheus.Counter)
+}
+
+// CurryWith return a view of this counter with labels bound, see Vector.CurryWith.
+func (c *Counter) CurryWith(labels prometheus.Labels) (*Counter, error) {
+	vec, err := c.Vector.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Counter{vec}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (c *Counter) MustCurryWith(labels prometheus.Labels) *Counter {
+	return &Counter{c.Vector.MustCurryWith(labels)}
+}
+
 // CounterUnit implement prometheus.Counter and Metric
 type CounterUnit struct {
-	val    float64
-	vec    *Vector
-	labels []string
-	last   time.Time
+	val     float64
+	vec     *Vector
+	labels  []string
+	last    time.Time
+	created time.Time
 
 	mtx sync.RWMutex
 }
 
 // NewCounterUnit will create new counter with specified label values.
 func NewCounterUnit(vec *Vector, labelValues []string) Metric {
+	now := time.Now()
 	return &CounterUnit{
-		vec:    vec,
-		labels: labelValues,
-		last:   time.Now(),
+		vec:     vec,
+		labels:  labelValues,
+		last:    now,
+		created: now,
 	}
 }
 
 // Desc implement prometheus.Counter (prometheus.Metric)
 func (u *CounterUnit) Desc() *prometheus.Desc {
-	return u.vec.desc
+	return u.vec.MetricDesc(u.labels)
 }
 
 // Write implement prometheus.Counter (prometheus.Metric)
@@ -68,7 +117,7 @@ func (u *CounterUnit) Write(metric *dto.Metric) error {
 	u.mtx.RLock()
 	defer u.mtx.RUnlock()
 
-	metric.Label = labelsToProto(u.vec.labels.ValuesToPromLabels(u.labels))
+	metric.Label = u.vec.MetricLabels(u.labels)
 	metric.Counter = &dto.Counter{Value: proto.Float64(u.val)}
 
 	return nil
@@ -76,7 +125,7 @@ func (u *CounterUnit) Write(metric *dto.Metric) error {
 
 // Describe implement prometheus.Counter (prometheus.Collector)
 func (u *CounterUnit) Describe(ch chan<- *prometheus.Desc) {
-	ch <- u.vec.desc
+	ch <- u.Desc()
 }
 
 // Collect implement prometheus.Counter (prometheus.Collector)
@@ -100,5 +149,18 @@ func (u *CounterUnit) Add(val float64) {
 
 // LastEdit implement Metric
 func (u *CounterUnit) LastEdit() time.Time {
+	u.mtx.RLock()
+	defer u.mtx.RUnlock()
+
 	return u.last
 }
+
+// Created return the time when this metric is created.
+func (u *CounterUnit) Created() time.Time {
+	return u.created
+}
+
+// MemorySize implement MemorySizer.
+func (u *CounterUnit) MemorySize() int {
+	return int(unsafe.Sizeof(*u)) + LabelValuesSize(u.labels)
+}
@@ -39,6 +39,18 @@ func TestCounter_With(t *testing.T) {
 	cv.With(prometheus.Labels{"label1": "value1"})
 }
 
+func TestCounter_WithLabelValues(t *testing.T) {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "vector", Help: "help", LabelNames: []string{"a", "b"}})
+
+	cv.WithLabelValues("1", "2").Inc()
+	m, err := cv.GetMetricWith(prometheus.Labels{"a": "1", "b": "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, m, cv.WithLabelValues("1", "2"))
+
+	_, err = cv.GetMetricWithLabelValues("1")
+	assert.Error(t, err)
+}
+
 func TestCounterUnit_Desc(t *testing.T) {
 	cv := createCounter(0)
 	counter := cv.With(prometheus.Labels{"label1": "value1"})
@@ -0,0 +1,83 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CurryWith return a view of this vector with labels bound to it. Labels passed to the view are
+// merged with the bound labels, and setting a bound label is an error. The view share metrics,
+// limits and GC with this vector, so only one of them should be registered.
+func (v *Vector) CurryWith(labels prometheus.Labels) (*Vector, error) {
+	curry := make(prometheus.Labels, len(v.curry)+len(labels))
+	for name, value := range v.curry {
+		curry[name] = value
+	}
+	for name, value := range labels {
+		if _, ok := curry[name]; ok {
+			return nil, fmt.Errorf("label %s is already curried", name)
+		}
+		curry[name] = value
+	}
+
+	return &Vector{vectorState: v.vectorState, curry: curry}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (v *Vector) MustCurryWith(labels prometheus.Labels) *Vector {
+	vec, err := v.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return vec
+}
+
+// curryLabels merge labels with curried labels.
+func (v *Vector) curryLabels(labels prometheus.Labels) (prometheus.Labels, error) {
+	if len(v.curry) == 0 {
+		return labels, nil
+	}
+
+	merged := make(prometheus.Labels, len(v.curry)+len(labels))
+	for name, value := range labels {
+		if _, ok := v.curry[name]; ok {
+			return nil, fmt.Errorf("label %s is already curried", name)
+		}
+		merged[name] = value
+	}
+	for name, value := range v.curry {
+		merged[name] = value
+	}
+
+	return merged, nil
+}
+
+// curryLabelValues return curried labels and labels from values of Opts.LabelNames that are not
+// curried, in order.
+func (v *Vector) curryLabelValues(values []string) (prometheus.Labels, error) {
+	var names []string
+	for _, name := range v.opts.LabelNames {
+		if _, ok := v.curry[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(values) != len(names) {
+		return nil, fmt.Errorf("vector with %s: expected %d label values but got %d", v.meta().desc.String(), len(names), len(values))
+	}
+
+	labels := make(prometheus.Labels, len(names)+len(v.curry))
+	for name, value := range v.curry {
+		labels[name] = value
+	}
+	for i, name := range names {
+		labels[name] = values[i]
+	}
+
+	return labels, nil
+}
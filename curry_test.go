@@ -0,0 +1,85 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_CurryWith(t *testing.T) {
+	cv := createCounter(0)
+	view, err := cv.CurryWith(prometheus.Labels{"label1": "a"})
+	assert.NoError(t, err)
+
+	view.With(prometheus.Labels{"label2": "b"}).Inc()
+	assert.Equal(t, cv.With(prometheus.Labels{"label1": "a", "label2": "b"}), view.With(prometheus.Labels{"label2": "b"}))
+	assert.Equal(t, 1, cv.Length())
+	assert.Equal(t, 1, view.Length())
+
+	_, err = view.GetMetricWith(prometheus.Labels{"label1": "b"})
+	assert.Error(t, err)
+	_, err = view.CurryWith(prometheus.Labels{"label1": "b"})
+	assert.Error(t, err)
+
+	nested := view.MustCurryWith(prometheus.Labels{"label2": "c"})
+	nested.With(prometheus.Labels{}).Inc()
+	nested.Handle(prometheus.Labels{}).Inc()
+	series, _ := cv.Series()
+	if assert.Equal(t, 2, len(series)) {
+		assert.Equal(t, prometheus.Labels{"label1": "a", "label2": "c"}, series[1].Labels)
+		assert.Equal(t, 2.0, series[1].Metric.GetCounter().GetValue())
+	}
+
+	_, err = view.Handle(prometheus.Labels{"label1": "x"}).Metric()
+	assert.Error(t, err)
+
+	assert.True(t, nested.Delete(prometheus.Labels{}))
+	assert.False(t, view.Delete(prometheus.Labels{"label1": "a"}))
+	assert.Equal(t, 1, cv.Length())
+	assert.Panics(t, func() { view.MustCurryWith(prometheus.Labels{"label1": "b"}) })
+}
+
+func TestVector_CurryWith_LabelValues(t *testing.T) {
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "g", Help: "help", LabelNames: []string{"method", "code"}})
+	view := gv.MustCurryWith(prometheus.Labels{"method": "GET", "handler": "/"})
+
+	view.WithLabelValues("200").Set(1)
+	series, _ := gv.Series()
+	if assert.Equal(t, 1, len(series)) {
+		assert.Equal(t, prometheus.Labels{"method": "GET", "code": "200", "handler": "/"}, series[0].Labels)
+	}
+
+	_, err := view.GetMetricWithLabelValues("GET", "200")
+	assert.Error(t, err)
+}
+
+func TestVector_CurryWith_SharedLimit(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{
+		Name:      "h",
+		Help:      "help",
+		Buckets:   []float64{1},
+		Expire:    time.Millisecond,
+		MaxLength: 2,
+	})
+	v1 := hv.MustCurryWith(prometheus.Labels{"lib": "a"})
+	v2, err := hv.CurryWith(prometheus.Labels{"lib": "b"})
+	assert.NoError(t, err)
+
+	v1.With(prometheus.Labels{}).Observe(1)
+	v2.With(prometheus.Labels{}).Observe(1)
+	v2.With(prometheus.Labels{"x": "y"}).Observe(1)
+	_, err = v1.GetMetricWith(prometheus.Labels{"x": "y"})
+	assert.Error(t, err)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 3, v1.GC().Deleted)
+	assert.Equal(t, 0, hv.Length())
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugPageSize is number of series shown in a page of debug handler.
+const debugPageSize = 100
+
+// DebugOpts is an option for creating debug handler.
+type DebugOpts struct {
+	// DeleteToken must be sent with every delete request. Empty mean deleting series is disabled.
+	DeleteToken string
+}
+
+type debugHandler struct {
+	vl   VectorLister
+	opts DebugOpts
+}
+
+type debugVector struct {
+	Name      string
+	Opts      Opts
+	Length    int
+	Memory    int
+	Keys      []string
+	GCHistory []GCRecord
+	Sites     []CreationSite
+}
+
+type debugSeries struct {
+	Labels string
+	Type   string
+	Value  string
+	Age    time.Duration
+}
+
+type debugVectorPage struct {
+	debugVector
+
+	Match     string
+	Error     string
+	Deleted   string
+	Total     int
+	Offset    int
+	Series    []debugSeries
+	Prev      string
+	Next      string
+	CanDelete bool
+}
+
+// NewDebugHandler return http.Handler that render HTML pages for browsing vectors, like
+// net/http/pprof. It is meant to be mounted at /debug/vectors. Series can be deleted by matchers
+// with POST request that carry DebugOpts.DeleteToken.
+func NewDebugHandler(vl VectorLister, opts DebugOpts) http.Handler {
+	return &debugHandler{vl: vl, opts: opts}
+}
+
+// ServeHTTP implement http.Handler.
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("name")
+	if name == "" {
+		h.serveIndex(w)
+		return
+	}
+
+	var vec *Vector
+	for _, v := range h.vl.Vectors() {
+		if v.Name() == name {
+			vec = v
+			break
+		}
+	}
+	if vec == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.serveVector(w, r, vec)
+	case http.MethodPost:
+		h.delete(w, r, vec)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *debugHandler) serveIndex(w http.ResponseWriter) {
+	var vectors []debugVector
+	for _, v := range h.vl.Vectors() {
+		vectors = append(vectors, newDebugVector(v))
+	}
+	sort.Slice(vectors, func(i, j int) bool { return vectors[i].Name < vectors[j].Name })
+
+	render(w, debugIndexTemplate, vectors)
+}
+
+func (h *debugHandler) serveVector(w http.ResponseWriter, r *http.Request, v *Vector) {
+	q := r.URL.Query()
+	page := debugVectorPage{
+		debugVector: newDebugVector(v),
+		Match:       q.Get("match"),
+		Deleted:     q.Get("deleted"),
+		CanDelete:   h.opts.DeleteToken != "",
+	}
+
+	matchers, err := parseMatcherLines(page.Match)
+	if err != nil {
+		page.Error = err.Error()
+		render(w, debugVectorTemplate, page)
+		return
+	}
+	if page.Offset, err = parseInt(q.Get("offset")); err != nil {
+		page.Error = "invalid offset: " + err.Error()
+		render(w, debugVectorTemplate, page)
+		return
+	}
+
+	series, err := v.Series(matchers...)
+	if err != nil {
+		page.Error = err.Error()
+		render(w, debugVectorTemplate, page)
+		return
+	}
+
+	page.Total = len(series)
+	if page.Offset > len(series) {
+		page.Offset = len(series)
+	}
+	series = series[page.Offset:]
+	if len(series) > debugPageSize {
+		series = series[:debugPageSize]
+		page.Next = debugURL(page.Name, page.Match, page.Offset+debugPageSize)
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - debugPageSize
+		if prev < 0 {
+			prev = 0
+		}
+		page.Prev = debugURL(page.Name, page.Match, prev)
+	}
+
+	now := time.Now()
+	for _, s := range series {
+		page.Series = append(page.Series, newDebugSeries(s, now))
+	}
+
+	render(w, debugVectorTemplate, page)
+}
+
+func (h *debugHandler) delete(w http.ResponseWriter, r *http.Request, v *Vector) {
+	token := r.PostFormValue("token")
+	if h.opts.DeleteToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.DeleteToken)) != 1 {
+		http.Error(w, "invalid delete token", http.StatusForbidden)
+		return
+	}
+
+	match := r.PostFormValue("match")
+	matchers, err := parseMatcherLines(match)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(matchers) == 0 {
+		http.Error(w, "at least one matcher is required", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := v.DeleteMatching(matchers...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	u := debugURL(v.Name(), match, 0) + "&deleted=" + url.QueryEscape(formatInt(deleted))
+	http.Redirect(w, r, u, http.StatusSeeOther)
+}
+
+func newDebugVector(v *Vector) debugVector {
+	v.mtx.RLock()
+	dv := debugVector{
+		Name:   v.Name(),
+		Opts:   v.Opts(),
+		Length: v.Length(),
+		Memory: v.memoryUsage(),
+		Keys:   append([]string(nil), v.labels.Keys...),
+	}
+	v.mtx.RUnlock()
+
+	dv.GCHistory = v.GCHistory()
+	dv.Sites = v.CreationSites()
+	sort.Strings(dv.Keys)
+
+	return dv
+}
+
+func newDebugSeries(s Series, now time.Time) debugSeries {
+	js := newJSONSeries(s)
+	ds := debugSeries{
+		Labels: labelsString(s.Labels),
+		Type:   js.Type,
+		Age:    now.Sub(s.LastEdit).Truncate(time.Millisecond),
+	}
+
+	switch {
+	case js.Value != nil:
+		ds.Value = formatFloat(*js.Value)
+	case js.Count != nil:
+		ds.Value = "count=" + formatInt(int(*js.Count)) + " sum=" + formatFloat(*js.Sum)
+	}
+
+	return ds
+}
+
+// parseMatcherLines parse matchers written one per line.
+func parseMatcherLines(s string) ([]*Matcher, error) {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return parseMatchers(lines)
+}
+
+func debugURL(name, match string, offset int) string {
+	q := url.Values{"name": {name}}
+	if match != "" {
+		q.Set("match", match)
+	}
+	if offset > 0 {
+		q.Set("offset", formatInt(offset))
+	}
+	return "?" + q.Encode()
+}
+
+func render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func formatInt(i int) string {
+	return strconv.Itoa(i)
+}
+
+var debugFuncs = template.FuncMap{
+	"lastGC": func(history []GCRecord) *GCRecord {
+		if len(history) == 0 {
+			return nil
+		}
+		return &history[len(history)-1]
+	},
+	"join": strings.Join,
+}
+
+const debugHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{template "title" .}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+td.num { text-align: right; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+`
+
+var debugIndexTemplate = template.Must(template.New("index").Funcs(debugFuncs).Parse(debugHeader + `{{define "title"}}Dynamic vectors{{end}}
+<h1>Dynamic vectors</h1>
+<table>
+<tr><th>Name</th><th>Help</th><th>Series</th><th>Memory</th><th>Expire</th><th>Label keys</th><th>Last GC</th></tr>
+{{range .}}<tr>
+<td><a href="?name={{.Name}}">{{.Name}}</a></td>
+<td>{{.Opts.Help}}</td>
+<td class="num">{{.Length}}{{if .Opts.MaxLength}} / {{.Opts.MaxLength}}{{end}}</td>
+<td class="num">{{.Memory}}{{if .Opts.MaxBytes}} / {{.Opts.MaxBytes}}{{end}}</td>
+<td>{{if .Opts.Expire}}{{.Opts.Expire}}{{else}}never{{end}}</td>
+<td>{{join .Keys ", "}}</td>
+<td>{{with lastGC .GCHistory}}{{.Time.Format "15:04:05"}}: {{.Deleted}} deleted{{if .LimitExceeded}}, limit exceeded{{end}}{{else}}never{{end}}</td>
+</tr>
+{{else}}<tr><td colspan="7">No vectors.</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var debugVectorTemplate = template.Must(template.New("vector").Funcs(debugFuncs).Parse(debugHeader + `{{define "title"}}{{.Name}}{{end}}
+<p><a href="?">All vectors</a></p>
+<h1>{{.Name}}</h1>
+<table>
+<tr><th>Help</th><td>{{.Opts.Help}}</td></tr>
+<tr><th>Const labels</th><td>{{range $k, $v := .Opts.ConstLabels}}{{$k}}="{{$v}}" {{end}}</td></tr>
+<tr><th>Label keys</th><td>{{join .Keys ", "}}</td></tr>
+<tr><th>Series</th><td>{{.Length}}{{if .Opts.MaxLength}} / {{.Opts.MaxLength}}{{end}}</td></tr>
+<tr><th>Memory</th><td>{{.Memory}}{{if .Opts.MaxBytes}} / {{.Opts.MaxBytes}}{{end}} bytes</td></tr>
+<tr><th>Expire</th><td>{{if .Opts.Expire}}{{.Opts.Expire}}{{else}}never{{end}}</td></tr>
+{{with .Opts.Buckets}}<tr><th>Buckets</th><td>{{range .}}{{.}} {{end}}</td></tr>{{end}}
+{{with .Opts.CreateRate}}<tr><th>Create rate</th><td>{{.}}/s</td></tr>{{end}}
+<tr><th>Unchecked</th><td>{{.Opts.Unchecked}}</td></tr>
+</table>
+
+<h2>GC history</h2>
+<table>
+<tr><th>Time</th><th>Duration</th><th>Deleted</th><th>Limit exceeded</th></tr>
+{{range .GCHistory}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Duration}}</td><td class="num">{{.Deleted}}</td><td>{{.LimitExceeded}}</td></tr>
+{{else}}<tr><td colspan="4">GC never run.</td></tr>
+{{end}}</table>
+
+{{if .Opts.CallerSampleRate}}<h2>Creation sites</h2>
+<p>1 in every {{.Opts.CallerSampleRate}} new series is sampled.</p>
+<table>
+<tr><th>Function</th><th>File</th><th>Label keys</th><th>Sampled</th><th>Estimated</th></tr>
+{{range .Sites}}<tr><td>{{.Function}}</td><td>{{.File}}:{{.Line}}</td><td>{{.Keys}}</td><td class="num">{{.Sampled}}</td><td class="num">{{.Estimated}}</td></tr>
+{{else}}<tr><td colspan="5">No creation is sampled yet.</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Series</h2>
+<form method="GET">
+<input type="hidden" name="name" value="{{.Name}}">
+<p>Matchers, one per line, e.g. method="GET" or code=~"5..":</p>
+<textarea name="match" rows="3" cols="60">{{.Match}}</textarea><br>
+<input type="submit" value="Filter">
+</form>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+{{with .Deleted}}<p>{{.}} series deleted.</p>{{end}}
+<p>{{.Total}} series.{{with .Prev}} <a href="{{.}}">Previous</a>{{end}}{{with .Next}} <a href="{{.}}">Next</a>{{end}}</p>
+<table>
+<tr><th>Labels</th><th>Type</th><th>Value</th><th>Last edit</th></tr>
+{{range .Series}}<tr><td>{{.Labels}}</td><td>{{.Type}}</td><td class="num">{{.Value}}</td><td>{{.Age}} ago</td></tr>
+{{end}}</table>
+
+{{if .CanDelete}}{{if .Match}}<h2>Delete</h2>
+<form method="POST">
+<input type="hidden" name="name" value="{{.Name}}">
+<input type="hidden" name="match" value="{{.Match}}">
+<p>Delete every series that match the filter above.</p>
+Token: <input type="password" name="token">
+<input type="submit" value="Delete">
+</form>{{end}}{{end}}
+</body>
+</html>
+`))
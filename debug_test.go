@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandler_Index(t *testing.T) {
+	cv := createCounter(10)
+	cv.With(prometheus.Labels{"label1": "a"}).Inc()
+	cv.GC()
+	h := dynamicvector.NewDebugHandler(dynamicvector.VectorList{cv.Vector}, dynamicvector.DebugOpts{})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, `<a href="?name=counter_vector">counter_vector</a>`)
+	assert.Contains(t, body, "1 / 10")
+	assert.Contains(t, body, "label1")
+	assert.Contains(t, body, ": 0 deleted")
+	assert.NotContains(t, body, "<script")
+	assert.NotContains(t, body, "http://")
+}
+
+func TestDebugHandler_Vector(t *testing.T) {
+	cv := createCounter(0)
+	for _, v := range []string{"a", "b", "<c>"} {
+		cv.With(prometheus.Labels{"label1": v}).Inc()
+	}
+	h := dynamicvector.NewDebugHandler(dynamicvector.VectorList{cv.Vector}, dynamicvector.DebugOpts{})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors?name=counter_vector", nil))
+	assert.Equal(t, 200, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "3 series.")
+	assert.Contains(t, body, `{label1=&#34;&lt;c&gt;&#34;}`)
+	assert.Contains(t, body, "GC never run.")
+	assert.NotContains(t, body, `method="POST"`)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors?name=counter_vector&match="+url.QueryEscape(`label1=~"a|b"`), nil))
+	assert.Contains(t, w.Body.String(), "2 series.")
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors?name=counter_vector&match=invalid", nil))
+	assert.Contains(t, w.Body.String(), `class="error"`)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors?name=unknown", nil))
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestDebugHandler_Delete(t *testing.T) {
+	cv := createCounter(0)
+	for _, v := range []string{"a", "b", "c"} {
+		cv.With(prometheus.Labels{"label1": v}).Inc()
+	}
+	h := dynamicvector.NewDebugHandler(dynamicvector.VectorList{cv.Vector}, dynamicvector.DebugOpts{DeleteToken: "secret"})
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/debug/vectors", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post(url.Values{"name": {"counter_vector"}, "match": {`label1="a"`}, "token": {"wrong"}})
+	assert.Equal(t, 403, w.Code)
+	w = post(url.Values{"name": {"counter_vector"}, "token": {"secret"}})
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, 3, cv.Length())
+
+	w = post(url.Values{"name": {"counter_vector"}, "match": {"label1=~\"a|b\"\n"}, "token": {"secret"}})
+	assert.Equal(t, 303, w.Code)
+	assert.Contains(t, w.Header().Get("Location"), "deleted=2")
+	assert.Equal(t, 1, cv.Length())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vectors?name=counter_vector&match=label1%3D%22c%22", nil))
+	assert.Contains(t, w.Body.String(), `method="POST"`)
+}
+
+func TestDebugHandler_DeleteDisabled(t *testing.T) {
+	cv := createCounter(0)
+	cv.With(prometheus.Labels{"label1": "a"}).Inc()
+	h := dynamicvector.NewDebugHandler(dynamicvector.VectorList{cv.Vector}, dynamicvector.DebugOpts{})
+
+	r := httptest.NewRequest("POST", "/debug/vectors?name=counter_vector&match=label1%3D%22a%22", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 403, w.Code)
+	assert.Equal(t, 1, cv.Length())
+}
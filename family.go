@@ -0,0 +1,235 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrFamilyLimit is returned when creating new vector in Family that already has MaxVectors vectors.
+var ErrFamilyLimit = errors.New("family exceed limit")
+
+// FamilyOpts is an option for creating Family.
+type FamilyOpts struct {
+	// Default is options for every vector in family. Its Name is replaced by metric name.
+	Default Opts
+
+	// Customize is called with metric type ("counter", "gauge" or "histogram") and options before
+	// a vector is created, so options such as Help and Buckets can be set per metric name. It is
+	// called without holding Family lock, so it may use the Family, and it may be called more than
+	// once for the same name when the name is used concurrently.
+	Customize func(typ string, opts *Opts)
+
+	// MaxVectors is maximum number of vectors in this Family. Each Family count its own vectors,
+	// set Default.Budget to limit number of metrics across families. Zero mean no limit.
+	MaxVectors int
+}
+
+// Family is a registry of vectors keyed by metric name. Vector is created and registered lazily
+// when a name is used for the first time.
+type Family struct {
+	opts FamilyOpts
+	reg  prometheus.Registerer
+
+	mtx     sync.RWMutex
+	members map[string]*member
+}
+
+type member struct {
+	typ       string
+	custom    bool // vector is made by Family.Vector with its own metric constructor.
+	vec       *Vector
+	collector prometheus.Collector
+}
+
+// NewFamily will create new Family that register created vectors to reg. Nil reg means vectors
+// are not registered.
+func NewFamily(opts FamilyOpts, reg prometheus.Registerer) *Family {
+	return &Family{
+		opts:    opts,
+		reg:     reg,
+		members: make(map[string]*member),
+	}
+}
+
+// CounterVec return counter vector with name, creating it if it does not exist yet.
+func (f *Family) CounterVec(name string) (*Counter, error) {
+	m, err := f.get(name, "counter", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.collector.(*Counter), nil
+}
+
+// GaugeVec return gauge vector with name, creating it if it does not exist yet.
+func (f *Family) GaugeVec(name string) (*Gauge, error) {
+	m, err := f.get(name, "gauge", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.collector.(*Gauge), nil
+}
+
+// HistogramVec return histogram vector with name, creating it if it does not exist yet.
+func (f *Family) HistogramVec(name string) (*Histogram, error) {
+	m, err := f.get(name, "histogram", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.collector.(*Histogram), nil
+}
+
+// Vector return vector with name whose metrics are made by cons, creating it if it does not exist
+// yet. It is used for metric types other than counter, gauge and histogram, typ is only used to tell
+// them apart and is passed to Customize.
+func (f *Family) Vector(name, typ string, cons func(v *Vector, labelValues []string) Metric) (*Vector, error) {
+	m, err := f.get(name, typ, cons)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.vec, nil
+}
+
+// Counter return counter with name and labels.
+func (f *Family) Counter(name string, labels prometheus.Labels) (prometheus.Counter, error) {
+	cv, err := f.CounterVec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return cv.GetMetricWith(labels)
+}
+
+// Gauge return gauge with name and labels.
+func (f *Family) Gauge(name string, labels prometheus.Labels) (prometheus.Gauge, error) {
+	gv, err := f.GaugeVec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return gv.GetMetricWith(labels)
+}
+
+// Histogram return histogram with name and labels.
+func (f *Family) Histogram(name string, labels prometheus.Labels) (prometheus.Histogram, error) {
+	hv, err := f.HistogramVec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return hv.GetMetricWith(labels)
+}
+
+// Length return number of vectors in family.
+func (f *Family) Length() int {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return len(f.members)
+}
+
+// Vectors implement VectorLister.
+func (f *Family) Vectors() []*Vector {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	vectors := make([]*Vector, 0, len(f.members))
+	for _, m := range f.members {
+		vectors = append(vectors, m.vec)
+	}
+
+	return vectors
+}
+
+// GC run GC on every vector in family.
+func (f *Family) GC() GCStat {
+	var stat GCStat
+	for _, v := range f.Vectors() {
+		s := v.GC()
+		stat.Deleted += s.Deleted
+		stat.LimitExceeded = stat.LimitExceeded || s.LimitExceeded
+	}
+
+	return stat
+}
+
+func (f *Family) get(name, typ string, cons func(*Vector, []string) Metric) (*member, error) {
+	f.mtx.RLock()
+	m, found := f.members[name]
+	f.mtx.RUnlock()
+
+	if !found {
+		var err error
+		if m, err = f.create(name, typ, cons); err != nil {
+			return nil, err
+		}
+	}
+	if m.typ != typ || m.custom != (cons != nil) {
+		return nil, fmt.Errorf("metric %s is %s, not %s", name, m.typ, typ)
+	}
+
+	return m, nil
+}
+
+func (f *Family) create(name, typ string, cons func(*Vector, []string) Metric) (*member, error) {
+	opts := f.opts.Default
+	opts.Name = name
+	if f.opts.Customize != nil {
+		f.opts.Customize(typ, &opts)
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if m, found := f.members[name]; found {
+		return m, nil
+	}
+	if f.opts.MaxVectors > 0 && len(f.members) >= f.opts.MaxVectors {
+		return nil, ErrFamilyLimit
+	}
+
+	m := &member{typ: typ, custom: cons != nil}
+	switch {
+	case cons != nil:
+		m.vec = NewVector(opts, cons)
+		m.collector = m.vec
+	case typ == "counter":
+		c := NewCounter(opts)
+		m.vec, m.collector = c.Vector, c
+	case typ == "gauge":
+		g := NewGauge(opts)
+		m.vec, m.collector = g.Vector, g
+	case typ == "histogram":
+		if opts.Buckets == nil {
+			opts.Buckets = prometheus.DefBuckets
+		}
+		h := NewHistogram(opts)
+		m.vec, m.collector = h.Vector, h
+	default:
+		return nil, fmt.Errorf("metric %s has unknown type %s", name, typ)
+	}
+
+	if f.reg != nil {
+		if err := f.reg.Register(m.collector); err != nil {
+			// vector is not used, give back its budget reservation.
+			if opts.Budget != nil {
+				opts.Budget.remove(m.vec)
+			}
+			return nil, err
+		}
+	}
+	f.members[name] = m
+
+	return m, nil
+}
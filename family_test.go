@@ -0,0 +1,144 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFamily_Counter(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	f := createFamily(0, reg)
+
+	c1, err := f.Counter("requests_total", prometheus.Labels{"code": "200"})
+	assert.NoError(t, err)
+	c1.Inc()
+	c2, err := f.Counter("requests_total", prometheus.Labels{"code": "200"})
+	assert.NoError(t, err)
+	assert.Equal(t, c1, c2)
+
+	cv, err := f.CounterVec("requests_total")
+	assert.NoError(t, err)
+	assert.Equal(t, "app_requests_total", cv.Name())
+	assert.Equal(t, 1, cv.Length())
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(mfs))
+	assert.Equal(t, "counter requests_total", mfs[0].GetHelp())
+}
+
+func TestFamily_GaugeHistogram(t *testing.T) {
+	f := createFamily(0, nil)
+
+	g, err := f.Gauge("temperature", prometheus.Labels{"room": "a"})
+	assert.NoError(t, err)
+	g.Set(1)
+
+	h, err := f.Histogram("latency", prometheus.Labels{})
+	assert.NoError(t, err)
+	h.Observe(1)
+
+	hv, _ := f.HistogramVec("latency")
+	series, _ := hv.Series()
+	assert.Equal(t, len(prometheus.DefBuckets), len(series[0].Metric.Histogram.Bucket))
+
+	assert.Equal(t, 2, f.Length())
+	assert.Equal(t, 2, len(f.Vectors()))
+}
+
+func TestFamily_TypeConflict(t *testing.T) {
+	f := createFamily(0, nil)
+
+	_, err := f.Counter("metric", prometheus.Labels{})
+	assert.NoError(t, err)
+	_, err = f.Gauge("metric", prometheus.Labels{})
+	assert.Error(t, err)
+	_, err = f.HistogramVec("metric")
+	assert.Error(t, err)
+}
+
+func TestFamily_Vector(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	f := createFamily(0, reg)
+
+	v1, err := f.Vector("metric", "counter", dynamicvector.NewGaugeUnit)
+	assert.NoError(t, err)
+	v2, err := f.Vector("metric", "counter", dynamicvector.NewGaugeUnit)
+	assert.NoError(t, err)
+	assert.Equal(t, v1, v2)
+	assert.Equal(t, "app_metric", v1.Name())
+
+	// custom vector is not a Counter even with the same type name.
+	_, err = f.CounterVec("metric")
+	assert.Error(t, err)
+	_, err = f.Vector("metric", "gauge", dynamicvector.NewGaugeUnit)
+	assert.Error(t, err)
+}
+
+func TestFamily_MaxVectors(t *testing.T) {
+	f := createFamily(1, nil)
+
+	_, err := f.CounterVec("metric1")
+	assert.NoError(t, err)
+	_, err = f.GaugeVec("metric2")
+	assert.Equal(t, dynamicvector.ErrFamilyLimit, err)
+	_, err = f.CounterVec("metric1")
+	assert.NoError(t, err)
+}
+
+func TestFamily_RegisterError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "metric", Help: "help"}))
+
+	budget := dynamicvector.NewBudget(dynamicvector.BudgetOpts{Max: 10})
+	f := dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Default: dynamicvector.Opts{Budget: budget, BudgetReserve: 5},
+	}, reg)
+
+	_, err := f.CounterVec("metric")
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(budget.Vectors()))
+}
+
+func TestFamily_Customize(t *testing.T) {
+	var f *dynamicvector.Family
+	f = dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Customize: func(typ string, opts *dynamicvector.Opts) {
+			// Customize may use the family.
+			f.Vectors()
+		},
+	}, nil)
+
+	_, err := f.CounterVec("metric")
+	assert.NoError(t, err)
+}
+
+func TestFamily_GC(t *testing.T) {
+	f := dynamicvector.NewFamily(dynamicvector.FamilyOpts{Default: dynamicvector.Opts{MaxLength: 1}}, nil)
+
+	f.Counter("metric1", prometheus.Labels{"a": "1"})
+	f.Counter("metric1", prometheus.Labels{"a": "2"})
+	f.Counter("metric2", prometheus.Labels{"a": "1"})
+
+	stat := f.GC()
+	assert.True(t, stat.LimitExceeded)
+	assert.Equal(t, 2, stat.Deleted)
+}
+
+func createFamily(max int, reg prometheus.Registerer) *dynamicvector.Family {
+	return dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Default: dynamicvector.Opts{Namespace: "app", Unchecked: true},
+		Customize: func(typ string, opts *dynamicvector.Opts) {
+			opts.Help = typ + " " + opts.Name
+		},
+		MaxVectors: max,
+	}, reg)
+}
@@ -0,0 +1,381 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package federate scrape prometheus text exposition from several targets and merge them into
+// dynamic vectors, so targets with unstable label sets can be exposed from a single endpoint.
+package federate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Target is a scrape target.
+type Target struct {
+	// URL of the metrics endpoint. Mandatory!
+	URL string
+
+	// Labels are added to every sample scraped from this target, for example instance. They
+	// override labels with the same name in the sample.
+	Labels prometheus.Labels
+}
+
+// Opts is an option for creating Aggregator.
+type Opts struct {
+	// Targets to scrape.
+	Targets []Target
+
+	// DropLabels are removed from every sample. Samples that become identical are merged: counter,
+	// untyped, histogram and summary count and sum are summed, summary quantiles are dropped, and
+	// gauge take the value from the last target in Targets. Histograms with different buckets are
+	// not merged, the first one is kept and Scrape return an error.
+	DropLabels []string
+
+	// Expire and MaxLength are applied to every created vector, see dynamicvector.Opts. Use Expire
+	// to clean up series of disappeared targets.
+	Expire    time.Duration
+	MaxLength int
+
+	// Client is used for scraping. Nil means http.DefaultClient.
+	Client *http.Client
+
+	// ErrorHandler is called when scrape in Run fail. Nil means error is ignored.
+	ErrorHandler func(error)
+}
+
+// Aggregator scrape targets and merge samples into dynamic vectors, one vector per metric family.
+// It is an unchecked prometheus.Collector.
+type Aggregator struct {
+	opts   Opts
+	drop   map[string]bool
+	family *dynamicvector.Family
+
+	mtx  sync.Mutex
+	last []scrapeResult // last successful scrape of every target.
+}
+
+type scrapeResult struct {
+	mfs  []*dto.MetricFamily
+	time time.Time
+}
+
+// NewAggregator will create new Aggregator.
+func NewAggregator(opts Opts) *Aggregator {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	drop := make(map[string]bool)
+	for _, name := range opts.DropLabels {
+		drop[name] = true
+	}
+
+	family := dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Default: dynamicvector.Opts{
+			Expire:    opts.Expire,
+			MaxLength: opts.MaxLength,
+			Unchecked: true,
+		},
+	}, nil)
+
+	return &Aggregator{
+		opts:   opts,
+		drop:   drop,
+		family: family,
+		last:   make([]scrapeResult, len(opts.Targets)),
+	}
+}
+
+// Scrape scrape all targets once and merge their samples. Targets that fail use samples of their
+// last successful scrape, so merged counters do not drop, until it is older than Expire. Errors of
+// failed targets are returned together.
+func (a *Aggregator) Scrape(ctx context.Context) error {
+	results := make([][]*dto.MetricFamily, len(a.opts.Targets))
+	errs := make([]error, len(a.opts.Targets))
+
+	var wg sync.WaitGroup
+	for i, t := range a.opts.Targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			results[i], errs[i] = a.scrape(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	a.mtx.Lock()
+	now := time.Now()
+	for i := range results {
+		if errs[i] == nil {
+			a.last[i] = scrapeResult{mfs: results[i], time: now}
+		} else if a.opts.Expire == 0 || now.Sub(a.last[i].time) < a.opts.Expire {
+			results[i] = a.last[i].mfs
+		}
+	}
+	a.mtx.Unlock()
+
+	merged := newMerger(a.drop)
+	for i, mfs := range results {
+		for _, mf := range mfs {
+			if err := merged.add(mf, a.opts.Targets[i].Labels); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for _, f := range merged.families() {
+		if err := a.apply(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, v := range a.Vectors() {
+		v.GC()
+	}
+
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("federate: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// Run will scrape every interval until ctx is done.
+func (a *Aggregator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Scrape(ctx); err != nil && a.opts.ErrorHandler != nil {
+				a.opts.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (a *Aggregator) Vectors() []*dynamicvector.Vector {
+	return a.family.Vectors()
+}
+
+// Describe implement prometheus.Collector. It send nothing, Aggregator is unchecked collector.
+func (a *Aggregator) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implement prometheus.Collector.
+func (a *Aggregator) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range a.Vectors() {
+		v.Collect(ch)
+	}
+}
+
+// Handler return http.Handler that expose merged metrics.
+func (a *Aggregator) Handler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(a)
+
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+func (a *Aggregator) scrape(ctx context.Context, t Target) ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest("GET", t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+
+	res, err := a.opts.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", t.URL, res.Status)
+	}
+
+	var mfs []*dto.MetricFamily
+	dec := expfmt.NewDecoder(res.Body, expfmt.ResponseFormat(res.Header))
+	for {
+		var mf dto.MetricFamily
+		if err := dec.Decode(&mf); err == io.EOF {
+			return mfs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %s", t.URL, err)
+		}
+		mfs = append(mfs, &mf)
+	}
+}
+
+// apply set merged family into its vector, creating the vector if it does not exist yet.
+func (a *Aggregator) apply(f *family) error {
+	v, err := a.family.Vector(f.name, strings.ToLower(f.typ.String()), NewUnit)
+	if err != nil {
+		return err
+	}
+	if v.Opts().Help != f.help {
+		if err := v.Update(func(opts *dynamicvector.Opts) { opts.Help = f.help }); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range f.series {
+		m, err := v.GetMetricWith(s.labels)
+		if err != nil {
+			return err
+		}
+		m.(*Unit).Set(s.metric)
+	}
+
+	return nil
+}
+
+type family struct {
+	name   string
+	help   string
+	typ    dto.MetricType
+	series map[string]*series
+}
+
+type series struct {
+	labels prometheus.Labels
+	metric *dto.Metric
+}
+
+// merger merge metric families from several targets.
+type merger struct {
+	drop   map[string]bool
+	byName map[string]*family
+}
+
+func newMerger(drop map[string]bool) *merger {
+	return &merger{drop: drop, byName: make(map[string]*family)}
+}
+
+func (m *merger) add(mf *dto.MetricFamily, extra prometheus.Labels) error {
+	f, ok := m.byName[mf.GetName()]
+	if !ok {
+		f = &family{name: mf.GetName(), help: mf.GetHelp(), typ: mf.GetType(), series: make(map[string]*series)}
+		m.byName[f.name] = f
+	} else if f.typ != mf.GetType() {
+		return fmt.Errorf("%s is %s, not %s", f.name, f.typ, mf.GetType())
+	}
+
+	var err error
+	for _, metric := range mf.Metric {
+		lbl := make(prometheus.Labels)
+		for _, lp := range metric.Label {
+			lbl[lp.GetName()] = lp.GetValue()
+		}
+		for name, value := range extra {
+			lbl[name] = value
+		}
+		for name := range m.drop {
+			delete(lbl, name)
+		}
+
+		key := labelsKey(lbl)
+		if s, ok := f.series[key]; !ok {
+			f.series[key] = &series{labels: lbl, metric: proto.Clone(metric).(*dto.Metric)}
+		} else if merr := merge(s.metric, metric); merr != nil {
+			err = fmt.Errorf("%s %v: %s", f.name, lbl, merr)
+		}
+	}
+
+	return err
+}
+
+func (m *merger) families() []*family {
+	families := make([]*family, 0, len(m.byName))
+	for _, f := range m.byName {
+		families = append(families, f)
+	}
+
+	return families
+}
+
+// merge merge src into dst, both have the same type. Histograms must have the same buckets.
+func merge(dst, src *dto.Metric) error {
+	switch {
+	case dst.Counter != nil:
+		dst.Counter.Value = proto.Float64(dst.Counter.GetValue() + src.Counter.GetValue())
+	case dst.Untyped != nil:
+		dst.Untyped.Value = proto.Float64(dst.Untyped.GetValue() + src.Untyped.GetValue())
+	case dst.Gauge != nil:
+		dst.Gauge.Value = proto.Float64(src.Gauge.GetValue())
+	case dst.Summary != nil:
+		dst.Summary.SampleCount = proto.Uint64(dst.Summary.GetSampleCount() + src.Summary.GetSampleCount())
+		dst.Summary.SampleSum = proto.Float64(dst.Summary.GetSampleSum() + src.Summary.GetSampleSum())
+		dst.Summary.Quantile = nil
+	case dst.Histogram != nil:
+		// cumulative counts of different bounds can not be summed, the result would not be
+		// monotonic.
+		dstBuckets, srcBuckets := sortedBuckets(dst.Histogram), sortedBuckets(src.Histogram)
+		if len(dstBuckets) != len(srcBuckets) {
+			return errors.New("histogram buckets differ between targets")
+		}
+		for i := range dstBuckets {
+			if dstBuckets[i].GetUpperBound() != srcBuckets[i].GetUpperBound() {
+				return errors.New("histogram buckets differ between targets")
+			}
+		}
+
+		dst.Histogram.SampleCount = proto.Uint64(dst.Histogram.GetSampleCount() + src.Histogram.GetSampleCount())
+		dst.Histogram.SampleSum = proto.Float64(dst.Histogram.GetSampleSum() + src.Histogram.GetSampleSum())
+		for i, b := range dstBuckets {
+			b.CumulativeCount = proto.Uint64(b.GetCumulativeCount() + srcBuckets[i].GetCumulativeCount())
+		}
+		dst.Histogram.Bucket = dstBuckets
+	}
+
+	return nil
+}
+
+func sortedBuckets(h *dto.Histogram) []*dto.Bucket {
+	buckets := append([]*dto.Bucket(nil), h.Bucket...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].GetUpperBound() < buckets[j].GetUpperBound() })
+
+	return buckets
+}
+
+func labelsKey(lbl prometheus.Labels) string {
+	names := make([]string, 0, len(lbl))
+	for name := range lbl {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(0)
+		b.WriteString(lbl[name])
+		b.WriteByte(0)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package federate_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/federate"
+	"github.com/stretchr/testify/assert"
+)
+
+const exposition1 = `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{code="200"} 10
+requests_total{code="500"} 1
+# HELP temperature Temperature.
+# TYPE temperature gauge
+temperature 20
+# HELP latency Latency.
+# TYPE latency histogram
+latency_bucket{le="1"} 1
+latency_bucket{le="+Inf"} 2
+latency_sum 3
+latency_count 2
+`
+
+const exposition2 = `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{code="200",path="/"} 5
+# HELP temperature Temperature.
+# TYPE temperature gauge
+temperature 25
+# HELP latency Latency.
+# TYPE latency histogram
+latency_bucket{le="1"} 2
+latency_bucket{le="+Inf"} 2
+latency_sum 1
+latency_count 2
+`
+
+func TestAggregator_Scrape(t *testing.T) {
+	srv1 := newTarget(exposition1)
+	defer srv1.Close()
+	srv2 := newTarget(exposition2)
+	defer srv2.Close()
+
+	a := federate.NewAggregator(federate.Opts{
+		Targets: []federate.Target{
+			{URL: srv1.URL, Labels: prometheus.Labels{"instance": "a"}},
+			{URL: srv2.URL, Labels: prometheus.Labels{"instance": "b"}},
+		},
+	})
+	assert.NoError(t, a.Scrape(context.Background()))
+
+	mfs := gather(t, a)
+	assert.Equal(t, 3, len(mfs["requests_total"].Metric))
+	assert.Equal(t, 2, len(mfs["temperature"].Metric))
+	assert.Equal(t, "Total requests.", mfs["requests_total"].GetHelp())
+	assert.Equal(t, 3, len(a.Vectors()))
+}
+
+func TestAggregator_Scrape_DropLabels(t *testing.T) {
+	srv1 := newTarget(exposition1)
+	defer srv1.Close()
+	srv2 := newTarget(exposition2)
+	defer srv2.Close()
+
+	a := federate.NewAggregator(federate.Opts{
+		Targets: []federate.Target{
+			{URL: srv1.URL, Labels: prometheus.Labels{"instance": "a"}},
+			{URL: srv2.URL, Labels: prometheus.Labels{"instance": "b", "path": "/"}},
+		},
+		DropLabels: []string{"instance", "path"},
+	})
+	assert.NoError(t, a.Scrape(context.Background()))
+
+	mfs := gather(t, a)
+	assert.Equal(t, 2, len(mfs["requests_total"].Metric))
+	for _, m := range mfs["requests_total"].Metric {
+		if m.Label[0].GetValue() == "200" {
+			assert.Equal(t, float64(15), m.Counter.GetValue())
+		}
+	}
+	assert.Equal(t, float64(25), mfs["temperature"].Metric[0].Gauge.GetValue())
+
+	h := mfs["latency"].Metric[0].Histogram
+	assert.Equal(t, uint64(4), h.GetSampleCount())
+	assert.Equal(t, float64(4), h.GetSampleSum())
+	assert.Equal(t, uint64(3), h.Bucket[0].GetCumulativeCount())
+}
+
+func TestAggregator_Scrape_BucketMismatch(t *testing.T) {
+	srv1 := newTarget(exposition1)
+	defer srv1.Close()
+	srv2 := newTarget(`# TYPE latency histogram
+latency_bucket{le="0.5"} 1
+latency_bucket{le="+Inf"} 1
+latency_sum 0.1
+latency_count 1
+`)
+	defer srv2.Close()
+
+	a := federate.NewAggregator(federate.Opts{
+		Targets: []federate.Target{
+			{URL: srv1.URL, Labels: prometheus.Labels{"instance": "a"}},
+			{URL: srv2.URL, Labels: prometheus.Labels{"instance": "b"}},
+		},
+		DropLabels: []string{"instance"},
+	})
+	assert.Error(t, a.Scrape(context.Background()))
+
+	// the first target is kept as is.
+	h := gather(t, a)["latency"].Metric[0].Histogram
+	assert.Equal(t, uint64(2), h.GetSampleCount())
+	assert.Equal(t, float64(1), h.Bucket[0].GetUpperBound())
+	assert.Equal(t, uint64(1), h.Bucket[0].GetCumulativeCount())
+}
+
+func TestAggregator_Scrape_Expire(t *testing.T) {
+	srv1 := newTarget(exposition1)
+	defer srv1.Close()
+	srv2 := newTarget(exposition2)
+
+	a := federate.NewAggregator(federate.Opts{
+		Targets: []federate.Target{
+			{URL: srv1.URL, Labels: prometheus.Labels{"instance": "a"}},
+			{URL: srv2.URL, Labels: prometheus.Labels{"instance": "b"}},
+		},
+		Expire: 50 * time.Millisecond,
+	})
+	assert.NoError(t, a.Scrape(context.Background()))
+	assert.Equal(t, 2, len(gather(t, a)["temperature"].Metric))
+
+	srv2.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.Error(t, a.Scrape(context.Background()))
+	assert.Equal(t, 1, len(gather(t, a)["temperature"].Metric))
+}
+
+func TestAggregator_Scrape_FailedTarget(t *testing.T) {
+	srv1 := newTarget(exposition1)
+	defer srv1.Close()
+	srv2 := newTarget(exposition2)
+
+	a := federate.NewAggregator(federate.Opts{
+		Targets:    []federate.Target{{URL: srv1.URL}, {URL: srv2.URL}},
+		DropLabels: []string{"path"},
+	})
+	assert.NoError(t, a.Scrape(context.Background()))
+
+	// failed target keep its last samples, so merged counter does not drop.
+	srv2.Close()
+	assert.Error(t, a.Scrape(context.Background()))
+	for _, m := range gather(t, a)["requests_total"].Metric {
+		if m.Label[0].GetValue() == "200" {
+			assert.Equal(t, float64(15), m.Counter.GetValue())
+		}
+	}
+}
+
+func TestAggregator_Handler(t *testing.T) {
+	srv := newTarget(exposition1)
+	defer srv.Close()
+
+	a := federate.NewAggregator(federate.Opts{Targets: []federate.Target{{URL: srv.URL}}})
+	assert.NoError(t, a.Scrape(context.Background()))
+
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	assert.Contains(t, string(body), `requests_total{code="200"} 10`)
+}
+
+func newTarget(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(strings.TrimLeft(body, "\n")))
+	}))
+}
+
+func gather(t *testing.T, c prometheus.Collector) map[string]*dto.MetricFamily {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+
+	res := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf
+	}
+
+	return res
+}
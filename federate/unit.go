@@ -0,0 +1,74 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package federate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Unit is a dynamicvector.Metric that hold value copied from scraped sample.
+type Unit struct {
+	vec    *dynamicvector.Vector
+	labels []string
+	value  dto.Metric
+	last   time.Time
+
+	mtx sync.RWMutex
+}
+
+// NewUnit will create new Unit with specified label values.
+func NewUnit(vec *dynamicvector.Vector, labelValues []string) dynamicvector.Metric {
+	return &Unit{
+		vec:    vec,
+		labels: labelValues,
+		last:   time.Now(),
+	}
+}
+
+// Desc implement prometheus.Metric
+func (u *Unit) Desc() *prometheus.Desc {
+	return u.vec.MetricDesc(u.labels)
+}
+
+// Write implement prometheus.Metric
+func (u *Unit) Write(metric *dto.Metric) error {
+	u.mtx.RLock()
+	defer u.mtx.RUnlock()
+
+	proto.Merge(metric, &u.value)
+	metric.Label = u.vec.MetricLabels(u.labels)
+
+	return nil
+}
+
+// Set replace value of this unit with m. Labels and timestamp of m are ignored.
+func (u *Unit) Set(m *dto.Metric) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.value = dto.Metric{
+		Counter:   m.Counter,
+		Gauge:     m.Gauge,
+		Summary:   m.Summary,
+		Untyped:   m.Untyped,
+		Histogram: m.Histogram,
+	}
+	u.last = time.Now()
+}
+
+// LastEdit implement dynamicvector.Metric
+func (u *Unit) LastEdit() time.Time {
+	u.mtx.RLock()
+	defer u.mtx.RUnlock()
+
+	return u.last
+}
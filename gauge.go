@@ -8,6 +8,7 @@ package dynamicvector
 import (
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -39,28 +40,76 @@ func (g *Gauge) With(labels prometheus.Labels) prometheus.Gauge {
 	return g.Vector.With(labels).(prometheus.Gauge)
 }
 
+// GetMetricWithLabelValues is a syntatic sugar for Vector.GetMetricWithLabelValues
+func (g *Gauge) GetMetricWithLabelValues(values ...string) (prometheus.Gauge, error) {
+	metric, err := g.Vector.GetMetricWithLabelValues(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.(prometheus.Gauge), nil
+}
+
+// WithLabelValues is a syntatic sugar for Vector.WithLabelValues
+func (g *Gauge) WithLabelValues(values ...string) prometheus.Gauge {
+	return g.Vector.WithLabelValues(values...).(prometheus.Gauge)
+}
+
+// GetMetricWithStruct is a syntatic sugar for Vector.GetMetricWithStruct
+func (g *Gauge) GetMetricWithStruct(s interface{}) (prometheus.Gauge, error) {
+	metric, err := g.Vector.GetMetricWithStruct(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.(prometheus.Gauge), nil
+}
+
+// WithStruct is a syntatic sugar for Vector.WithStruct
+func (g *Gauge) WithStruct(s interface{}) prometheus.Gauge {
+	return g.Vector.WithStruct(s).(prometheus.Gauge)
+}
+
+// CurryWith return a view of this gauge with labels bound, see Vector.CurryWith.
+func (g *Gauge) CurryWith(labels prometheus.Labels) (*Gauge, error) {
+	vec, err := g.Vector.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gauge{vec}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (g *Gauge) MustCurryWith(labels prometheus.Labels) *Gauge {
+	return &Gauge{g.Vector.MustCurryWith(labels)}
+}
+
 // GaugeUnit implement prometheus.Gauge and Metric
 type GaugeUnit struct {
-	val    float64
-	vec    *Vector
-	labels []string
-	last   time.Time
+	val     float64
+	vec     *Vector
+	labels  []string
+	last    time.Time
+	created time.Time
 
 	mtx sync.RWMutex
 }
 
 // NewGaugeUnit will create new counter with specified label values.
 func NewGaugeUnit(vec *Vector, labelValues []string) Metric {
+	now := time.Now()
 	return &GaugeUnit{
-		vec:    vec,
-		labels: labelValues,
-		last:   time.Now(),
+		vec:     vec,
+		labels:  labelValues,
+		last:    now,
+		created: now,
 	}
 }
 
 // Desc implement prometheus.Gauge (prometheus.Metric)
 func (u *GaugeUnit) Desc() *prometheus.Desc {
-	return u.vec.desc
+	return u.vec.MetricDesc(u.labels)
 }
 
 // Write implement prometheus.Gauge (prometheus.Metric)
@@ -68,7 +117,7 @@ func (u *GaugeUnit) Write(metric *dto.Metric) error {
 	u.mtx.RLock()
 	defer u.mtx.RUnlock()
 
-	metric.Label = labelsToProto(u.vec.labels.ValuesToPromLabels(u.labels))
+	metric.Label = u.vec.MetricLabels(u.labels)
 	metric.Gauge = &dto.Gauge{Value: proto.Float64(u.val)}
 
 	return nil
@@ -76,7 +125,7 @@ func (u *GaugeUnit) Write(metric *dto.Metric) error {
 
 // Describe implement prometheus.Gauge (prometheus.Collector)
 func (u *GaugeUnit) Describe(ch chan<- *prometheus.Desc) {
-	ch <- u.vec.desc
+	ch <- u.Desc()
 }
 
 // Collect implement prometheus.Gauge (prometheus.Collector)
@@ -125,5 +174,18 @@ func (u *GaugeUnit) SetToCurrentTime() {
 
 // LastEdit implement Metric
 func (u *GaugeUnit) LastEdit() time.Time {
+	u.mtx.RLock()
+	defer u.mtx.RUnlock()
+
 	return u.last
 }
+
+// Created return the time when this metric is created.
+func (u *GaugeUnit) Created() time.Time {
+	return u.created
+}
+
+// MemorySize implement MemorySizer.
+func (u *GaugeUnit) MemorySize() int {
+	return int(unsafe.Sizeof(*u)) + LabelValuesSize(u.labels)
+}
@@ -39,6 +39,18 @@ func TestGauge_With(t *testing.T) {
 	v.With(prometheus.Labels{"label1": "value1"})
 }
 
+func TestGauge_WithLabelValues(t *testing.T) {
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "vector", Help: "help", LabelNames: []string{"a", "b"}})
+
+	gv.WithLabelValues("1", "2").Set(1)
+	m, err := gv.GetMetricWith(prometheus.Labels{"a": "1", "b": "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, m, gv.WithLabelValues("1", "2"))
+
+	_, err = gv.GetMetricWithLabelValues("1")
+	assert.Error(t, err)
+}
+
 func TestGaugeUnit_Desc(t *testing.T) {
 	v := createGauge(0)
 	gauge := v.With(prometheus.Labels{"label1": "value1"})
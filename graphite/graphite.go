@@ -0,0 +1,310 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package graphite periodically push dynamicvector metrics to Carbon plaintext protocol over TCP.
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Format is the line format sent to Carbon.
+type Format int
+
+const (
+	// Tagged is Graphite 1.1 tagged format, name;tag=value value timestamp.
+	Tagged Format = iota
+
+	// Dotted put labels into the path, name.key.value value timestamp.
+	Dotted
+)
+
+// DefaultBufferSize is default value for Config.BufferSize.
+const DefaultBufferSize = 1 << 20
+
+// Config is configuration for Pusher.
+type Config struct {
+	// Addr is Carbon plaintext address, for example localhost:2003. Mandatory!
+	Addr string
+
+	// Prefix is prepended to every metric name, separated by dot.
+	Prefix string
+
+	// Format is the line format. Default is Tagged.
+	Format Format
+
+	// PathLabels is the order of labels in Dotted path. Labels that are not listed are put after
+	// them, sorted by name.
+	PathLabels []string
+
+	// Escape replace characters that are not allowed in path segment or tag. Default is EscapeTag
+	// for Tagged format and EscapePath for Dotted format.
+	Escape func(string) string
+
+	// Timeout for dial and write. Default is 10 seconds.
+	Timeout time.Duration
+
+	// BufferSize is maximum bytes of unsent lines that are kept to be retried on next push.
+	// Zero means DefaultBufferSize.
+	BufferSize int
+
+	// ErrorHandler is called when push in Run fail. Nil means error is ignored.
+	ErrorHandler func(error)
+}
+
+// Pusher push vectors to Carbon. When Carbon is not reachable, unsent lines are kept and sent on
+// next push, so restarting Carbon does not lose the last flush.
+type Pusher struct {
+	cfg     Config
+	vectors dynamicvector.VectorLister
+
+	mtx     sync.Mutex
+	conn    net.Conn
+	pending []byte // lines that failed to be sent
+}
+
+// NewPusher will create new Pusher.
+func NewPusher(cfg Config, vl dynamicvector.VectorLister) (*Pusher, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("graphite: Addr is mandatory")
+	}
+	if cfg.Escape == nil && cfg.Format == Dotted {
+		cfg.Escape = EscapePath
+	} else if cfg.Escape == nil {
+		cfg.Escape = EscapeTag
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+
+	return &Pusher{cfg: cfg, vectors: vl}, nil
+}
+
+// EscapePath replace anything other than letters, digits, '_', '-' and ':' with '_'.
+func EscapePath(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == ':' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// EscapeTag replace characters that are not allowed in Graphite tag, which are ';', '~', '=', '!',
+// '^' and whitespace, with '_'.
+func EscapeTag(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(";~=!^", r) || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Push send all live metrics once, including lines that failed on previous push.
+func (p *Pusher) Push() error {
+	var buf bytes.Buffer
+	ts := time.Now().Unix()
+	for _, v := range p.vectors.Vectors() {
+		series, err := v.Series()
+		if err != nil {
+			return err
+		}
+		for _, s := range series {
+			p.writeSeries(&buf, v.Name(), s, ts)
+		}
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	data := append(p.pending, buf.Bytes()...)
+	p.pending = nil
+
+	n, err := p.write(data)
+	if err != nil {
+		// the connection may be dead, Carbon may have been restarted. Reconnect once and send
+		// the rest.
+		p.close()
+		data = data[n:]
+		n, err = p.write(data)
+	}
+	if err != nil {
+		p.close()
+		p.pending = truncate(data[n:], p.cfg.BufferSize)
+	}
+
+	return err
+}
+
+// Run will push every interval until ctx is done.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Push(); err != nil && p.cfg.ErrorHandler != nil {
+				p.cfg.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// Close close connection to Carbon.
+func (p *Pusher) Close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.close()
+}
+
+// write send data and return number of bytes that were written.
+func (p *Pusher) write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	if p.conn == nil {
+		conn, err := net.DialTimeout("tcp", p.cfg.Addr, p.cfg.Timeout)
+		if err != nil {
+			return 0, err
+		}
+		p.conn = conn
+	}
+
+	p.conn.SetWriteDeadline(time.Now().Add(p.cfg.Timeout))
+	return p.conn.Write(data)
+}
+
+func (p *Pusher) close() error {
+	if p.conn == nil {
+		return nil
+	}
+
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// truncate drop oldest lines so data is not bigger than size.
+func truncate(data []byte, size int) []byte {
+	if len(data) <= size {
+		return data
+	}
+
+	data = data[len(data)-size:]
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return append([]byte(nil), data[i+1:]...)
+	}
+	return nil
+}
+
+func (p *Pusher) writeSeries(buf *bytes.Buffer, name string, s dynamicvector.Series, ts int64) {
+	m := s.Metric
+	switch {
+	case m.Counter != nil:
+		p.writeLine(buf, name, s.Labels, m.Counter.GetValue(), ts)
+	case m.Gauge != nil:
+		p.writeLine(buf, name, s.Labels, m.Gauge.GetValue(), ts)
+	case m.Untyped != nil:
+		p.writeLine(buf, name, s.Labels, m.Untyped.GetValue(), ts)
+	case m.Summary != nil:
+		p.writeLine(buf, name+"_count", s.Labels, float64(m.Summary.GetSampleCount()), ts)
+		p.writeLine(buf, name+"_sum", s.Labels, m.Summary.GetSampleSum(), ts)
+	case m.Histogram != nil:
+		p.writeLine(buf, name+"_count", s.Labels, float64(m.Histogram.GetSampleCount()), ts)
+		p.writeLine(buf, name+"_sum", s.Labels, m.Histogram.GetSampleSum(), ts)
+
+		buckets := append([]*dto.Bucket(nil), m.Histogram.Bucket...)
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].GetUpperBound() < buckets[j].GetUpperBound() })
+		for _, b := range buckets {
+			p.writeLine(buf, name+"_bucket", withLabel(s.Labels, "le", b.GetUpperBound()), float64(b.GetCumulativeCount()), ts)
+		}
+		p.writeLine(buf, name+"_bucket", withLabel(s.Labels, "le", math.Inf(1)), float64(m.Histogram.GetSampleCount()), ts)
+	}
+}
+
+func (p *Pusher) writeLine(buf *bytes.Buffer, name string, lbl prometheus.Labels, value float64, ts int64) {
+	if p.cfg.Prefix != "" {
+		buf.WriteString(p.cfg.Prefix)
+		buf.WriteByte('.')
+	}
+	buf.WriteString(p.cfg.Escape(name))
+
+	for _, key := range p.labelOrder(lbl) {
+		if p.cfg.Format == Dotted {
+			buf.WriteByte('.')
+			buf.WriteString(p.cfg.Escape(key))
+			buf.WriteByte('.')
+		} else {
+			buf.WriteByte(';')
+			buf.WriteString(p.cfg.Escape(key))
+			buf.WriteByte('=')
+		}
+		buf.WriteString(p.cfg.Escape(lbl[key]))
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(ts, 10))
+	buf.WriteByte('\n')
+}
+
+// labelOrder return label keys in order they are written. Tagged format sort them by name, Carbon
+// does not care about the order anyway.
+func (p *Pusher) labelOrder(lbl prometheus.Labels) []string {
+	keys := make([]string, 0, len(lbl))
+	seen := make(map[string]bool)
+	if p.cfg.Format == Dotted {
+		for _, key := range p.cfg.PathLabels {
+			if _, ok := lbl[key]; ok && !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+
+	var rest []string
+	for key := range lbl {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
+func withLabel(lbl prometheus.Labels, key string, bound float64) prometheus.Labels {
+	res := make(prometheus.Labels, len(lbl)+1)
+	for k, v := range lbl {
+		res[k] = v
+	}
+	res[key] = strconv.FormatFloat(bound, 'g', -1, 64)
+
+	return res
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package graphite_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/graphite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPusher_Tagged(t *testing.T) {
+	carbon := newCarbon(t, "127.0.0.1:0")
+	defer carbon.Close()
+
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "latency", Buckets: []float64{0.5}})
+	hv.With(prometheus.Labels{"path": "/a;b"}).Observe(1)
+
+	p, err := graphite.NewPusher(graphite.Config{Addr: carbon.Addr().String(), Prefix: "app"}, dynamicvector.VectorList{hv.Vector})
+	assert.NoError(t, err)
+	defer p.Close()
+	assert.NoError(t, p.Push())
+
+	lines := carbon.read(t, 4)
+	assert.Equal(t, []string{
+		"app.latency_count;path=/a_b 1",
+		"app.latency_sum;path=/a_b 1",
+		"app.latency_bucket;le=0.5;path=/a_b 0",
+		"app.latency_bucket;le=+Inf;path=/a_b 1",
+	}, lines)
+}
+
+func TestPusher_Dotted(t *testing.T) {
+	carbon := newCarbon(t, "127.0.0.1:0")
+	defer carbon.Close()
+
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "requests_total"})
+	cv.With(prometheus.Labels{"path": "/index.html", "code": "200", "host": "a"}).Add(2)
+
+	p, err := graphite.NewPusher(graphite.Config{
+		Addr:       carbon.Addr().String(),
+		Format:     graphite.Dotted,
+		PathLabels: []string{"host", "path"},
+	}, dynamicvector.VectorList{cv.Vector})
+	assert.NoError(t, err)
+	defer p.Close()
+	assert.NoError(t, p.Push())
+
+	assert.Equal(t, []string{"requests_total.host.a.path._index_html.code.200 2"}, carbon.read(t, 1))
+}
+
+func TestPusher_Reconnect(t *testing.T) {
+	carbon := newCarbon(t, "127.0.0.1:0")
+	addr := carbon.Addr().String()
+
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "temperature"})
+	g := gv.With(prometheus.Labels{})
+	p, err := graphite.NewPusher(graphite.Config{Addr: addr, Timeout: time.Second}, dynamicvector.VectorList{gv.Vector})
+	assert.NoError(t, err)
+	defer p.Close()
+
+	g.Set(1)
+	assert.NoError(t, p.Push())
+	assert.Equal(t, []string{"temperature 1"}, carbon.read(t, 1))
+
+	// carbon restart. Write to a connection closed by peer may succeed until the peer reset it, the
+	// flush that fail must not be lost.
+	carbon.Close()
+	g.Set(2)
+	deadline := time.Now().Add(time.Second)
+	for p.Push() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("push to closed carbon does not fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	carbon = newCarbon(t, addr)
+	defer carbon.Close()
+	g.Set(3)
+	assert.NoError(t, p.Push())
+	assert.Equal(t, []string{"temperature 2", "temperature 3"}, carbon.read(t, 2))
+}
+
+type carbon struct {
+	net.Listener
+	lines chan string
+}
+
+func newCarbon(t *testing.T, addr string) *carbon {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &carbon{Listener: l, lines: make(chan string, 100)}
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					c.lines <- s.Text()
+				}
+			}()
+		}
+	}()
+
+	return c
+}
+
+// read return n lines without the timestamp.
+func (c *carbon) read(t *testing.T, n int) []string {
+	var lines []string
+	for len(lines) < n {
+		select {
+		case line := <-c.lines:
+			lines = append(lines, line[:strings.LastIndexByte(line, ' ')])
+		case <-time.After(time.Second):
+			t.Fatalf("timeout, got %v", lines)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package graphite
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/internal/bridge"
+)
+
+// ServerOpts is an option for creating Server.
+type ServerOpts struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	// Templates turn dotted path into metric name and labels. The first template that match the
+	// path is used. Path that match no template become metric name as is.
+	Templates []*Template
+
+	// Expire and MaxLength are applied to every created vector, see dynamicvector.Opts.
+	Expire    time.Duration
+	MaxLength int
+
+	// ErrorHandler is called with every line that can not be handled. Nil means error is ignored.
+	ErrorHandler func(error)
+}
+
+// Server accept Graphite plaintext protocol over TCP and feed it into dynamic Gauge vectors, one
+// vector per metric name. Both tagged path, name;tag=value, and dotted path are accepted.
+type Server struct {
+	opts   ServerOpts
+	family *dynamicvector.Family
+
+	closers bridge.Closers
+}
+
+// NewServer will create new Server that register created vectors to reg.
+func NewServer(opts ServerOpts, reg prometheus.Registerer) *Server {
+	family := dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Default: dynamicvector.Opts{
+			Namespace: opts.Namespace,
+			Expire:    opts.Expire,
+			MaxLength: opts.MaxLength,
+			Unchecked: true,
+		},
+		Customize: func(typ string, o *dynamicvector.Opts) {
+			o.Help = fmt.Sprintf("Graphite metric %s", o.Name)
+		},
+	}, reg)
+
+	return &Server{
+		opts:   opts,
+		family: family,
+	}
+}
+
+// Handle parse and apply a single line, path value [timestamp].
+func (s *Server) Handle(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return fmt.Errorf("graphite: invalid line %q", line)
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return fmt.Errorf("graphite: invalid line %q: %s", line, err)
+	}
+
+	name, lbl, err := s.parsePath(fields[0])
+	if err != nil {
+		return err
+	}
+
+	g, err := s.family.Gauge(name, lbl)
+	if err != nil {
+		return err
+	}
+	g.Set(value)
+
+	return nil
+}
+
+// parsePath return metric name and labels of path.
+func (s *Server) parsePath(path string) (string, prometheus.Labels, error) {
+	if strings.Contains(path, ";") {
+		tags := strings.Split(path, ";")
+		lbl := make(prometheus.Labels)
+		for _, tag := range tags[1:] {
+			i := strings.IndexByte(tag, '=')
+			if i <= 0 || i == len(tag)-1 {
+				return "", nil, fmt.Errorf("graphite: invalid tag %q in %q", tag, path)
+			}
+			lbl[bridge.SanitizeLabel(tag[:i])] = tag[i+1:]
+		}
+		return bridge.SanitizeName(tags[0]), lbl, nil
+	}
+
+	segments := strings.Split(path, ".")
+	for _, t := range s.opts.Templates {
+		if t.Match(segments) {
+			name, lbl := t.Apply(segments)
+			if name == "" {
+				return "", nil, fmt.Errorf("graphite: path %q has no measurement", path)
+			}
+
+			res := make(prometheus.Labels, len(lbl))
+			for k, v := range lbl {
+				res[bridge.SanitizeLabel(k)] = v
+			}
+			return bridge.SanitizeName(name), res, nil
+		}
+	}
+
+	return bridge.SanitizeName(path), prometheus.Labels{}, nil
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (s *Server) Vectors() []*dynamicvector.Vector {
+	return s.family.Vectors()
+}
+
+// ListenAndServe listen on TCP addr and serve it until Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accept connections from l and read newline separated lines from them.
+func (s *Server) Serve(l net.Listener) error {
+	s.closers.Track(l, true)
+	defer s.closers.Track(l, false)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.closers.Track(conn, true)
+
+		go func() {
+			defer s.closers.Track(conn, false)
+			defer conn.Close()
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				if err := s.Handle(scanner.Text()); err != nil && s.opts.ErrorHandler != nil {
+					s.opts.ErrorHandler(err)
+				}
+			}
+		}()
+	}
+}
+
+// Close stop all listeners and connections.
+func (s *Server) Close() error {
+	return s.closers.Close()
+}
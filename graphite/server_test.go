@@ -0,0 +1,76 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package graphite_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/graphite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Handle(t *testing.T) {
+	tmpl, _ := graphite.ParseTemplate("servers.* .host.measurement*")
+	reg := prometheus.NewPedanticRegistry()
+	s := graphite.NewServer(graphite.ServerOpts{Namespace: "graphite", Templates: []*graphite.Template{tmpl}}, reg)
+
+	assert.NoError(t, s.Handle("servers.web1.cpu.load 0.5 1500000000"))
+	assert.NoError(t, s.Handle("servers.web2.cpu.load 1.5"))
+	assert.NoError(t, s.Handle("disk.used;host=web1;mount=/ 42 1500000000"))
+	assert.NoError(t, s.Handle("legacy.counter 7"))
+	assert.NoError(t, s.Handle(""))
+
+	assert.Error(t, s.Handle("invalid"))
+	assert.Error(t, s.Handle("a.b x"))
+	assert.Error(t, s.Handle("a;b 1"))
+
+	mfs := gather(t, reg)
+	assert.Equal(t, 2, len(mfs["graphite_cpu_load"].Metric))
+	assert.Equal(t, float64(42), mfs["graphite_disk_used"].Metric[0].Gauge.GetValue())
+	assert.Equal(t, 2, len(mfs["graphite_disk_used"].Metric[0].Label))
+	assert.Equal(t, float64(7), mfs["graphite_legacy_counter"].Metric[0].Gauge.GetValue())
+	assert.Equal(t, 3, len(s.Vectors()))
+}
+
+func TestServer_Serve(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s := graphite.NewServer(graphite.ServerOpts{}, reg)
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	conn.Write([]byte("temperature;room=a 21\ntemperature;room=b 22\n"))
+	conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if mf, ok := gather(t, reg)["temperature"]; ok && len(mf.Metric) == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("not all lines are received")
+}
+
+func gather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	mfs, err := g.Gather()
+	assert.NoError(t, err)
+
+	res := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf
+	}
+
+	return res
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package graphite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Template turn dotted path into metric name and labels. Every part of the pattern is matched with
+// path segment at the same position:
+//   - measurement: segment become part of metric name.
+//   - measurement*: this and all remaining segments become part of metric name.
+//   - empty: segment is skipped.
+//   - anything else: segment become value of label with that name.
+//
+// Metric name parts are joined with '_'. For example pattern env.host.measurement* turn
+// prod.web1.cpu.load into cpu_load{env="prod",host="web1"}.
+type Template struct {
+	filter []string
+	parts  []string
+}
+
+// ParseTemplate will parse template in form of "[filter ]pattern". Filter is dotted path where '*'
+// match any segment, only path that match it and has at least as many segments use this template.
+func ParseTemplate(s string) (*Template, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("graphite: invalid template %q", s)
+	}
+
+	t := &Template{parts: strings.Split(fields[len(fields)-1], ".")}
+	if len(fields) == 2 {
+		t.filter = strings.Split(fields[0], ".")
+	}
+
+	hasMeasurement := false
+	for i, part := range t.parts {
+		if part == "measurement*" && i != len(t.parts)-1 {
+			return nil, fmt.Errorf("graphite: invalid template %q: measurement* must be the last", s)
+		}
+		if strings.HasPrefix(part, "measurement") {
+			hasMeasurement = true
+		}
+	}
+	if !hasMeasurement {
+		return nil, fmt.Errorf("graphite: invalid template %q: no measurement", s)
+	}
+
+	return t, nil
+}
+
+// Match will check whether path match template filter.
+func (t *Template) Match(segments []string) bool {
+	if len(segments) < len(t.filter) {
+		return false
+	}
+
+	for i, f := range t.filter {
+		if f != "*" && f != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply return metric name and labels for path segments.
+func (t *Template) Apply(segments []string) (string, prometheus.Labels) {
+	var names []string
+	lbl := make(prometheus.Labels)
+
+	for i, seg := range segments {
+		if i >= len(t.parts) {
+			break
+		}
+
+		switch part := t.parts[i]; part {
+		case "measurement":
+			names = append(names, seg)
+		case "measurement*":
+			names = append(names, segments[i:]...)
+		case "":
+		default:
+			lbl[part] = seg
+		}
+	}
+
+	return strings.Join(names, "_"), lbl
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package graphite_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/graphite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := graphite.ParseTemplate("env.host.measurement*")
+	assert.NoError(t, err)
+	assert.True(t, tmpl.Match(strings.Split("prod.web1.cpu.load", ".")))
+
+	name, lbl := tmpl.Apply(strings.Split("prod.web1.cpu.load", "."))
+	assert.Equal(t, "cpu_load", name)
+	assert.Equal(t, prometheus.Labels{"env": "prod", "host": "web1"}, lbl)
+
+	tmpl, err = graphite.ParseTemplate("servers.* .host.measurement.field")
+	assert.NoError(t, err)
+	assert.True(t, tmpl.Match(strings.Split("servers.web1.cpu.idle", ".")))
+	assert.False(t, tmpl.Match(strings.Split("apps.web1.cpu.idle", ".")))
+	assert.False(t, tmpl.Match([]string{"servers"}))
+
+	name, lbl = tmpl.Apply(strings.Split("servers.web1.cpu.idle", "."))
+	assert.Equal(t, "cpu", name)
+	assert.Equal(t, prometheus.Labels{"host": "web1", "field": "idle"}, lbl)
+}
+
+func TestParseTemplate_Error(t *testing.T) {
+	for _, s := range []string{"", "a b c", "env.host", "measurement*.host"} {
+		_, err := graphite.ParseTemplate(s)
+		assert.Error(t, err, s)
+	}
+}
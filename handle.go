@@ -0,0 +1,168 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Handle is a precomputed lookup key of a metric in vector. Looking up metric with Handle does not
+// allocate. It stay valid when the metric is deleted by GC, a new metric is created on next lookup.
+// Handle is safe for concurrent use.
+type Handle struct {
+	vec    *Vector
+	labels prometheus.Labels
+	err    error        // error of merging curried labels.
+	key    atomic.Value // handleKey
+}
+
+type handleKey struct {
+	gen      uint64 // Vector.keysGen when hash is computed.
+	hash     uint64
+	included bool
+}
+
+// Handle return Handle of metric with labels. The metric is not created until it is used.
+func (v *Vector) Handle(labels prometheus.Labels) *Handle {
+	h := &Handle{vec: v.root, labels: copyLabels(labels)}
+	if h.labels == nil {
+		h.labels = prometheus.Labels{}
+	}
+	h.labels, h.err = v.curryLabels(h.labels)
+	h.key.Store(handleKey{gen: ^uint64(0)})
+
+	return h
+}
+
+// Metric return the metric of this handle. It create the metric if it does not exist, and return
+// error like Vector.GetMetricWith.
+func (h *Handle) Metric() (prometheus.Metric, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	v := h.vec
+
+	v.mtx.RLock()
+	key := h.key.Load().(handleKey)
+	if key.gen != v.keysGen {
+		// label keys of vector changed, so hash must be recomputed.
+		key = handleKey{gen: v.keysGen, included: v.labels.Include(h.labels)}
+		if key.included {
+			key.hash = v.labels.Hash(h.labels)
+		}
+		h.key.Store(key)
+	}
+
+	var metric Metric
+	if key.included {
+		metric = v.metrics[key.hash]
+	}
+	v.mtx.RUnlock()
+
+	if metric != nil {
+		return metric, nil
+	}
+	return v.GetMetricWith(h.labels)
+}
+
+// CounterHandle is Handle of a counter.
+type CounterHandle struct {
+	*Handle
+}
+
+// Handle return CounterHandle of counter with labels.
+func (c *Counter) Handle(labels prometheus.Labels) *CounterHandle {
+	return &CounterHandle{c.Vector.Handle(labels)}
+}
+
+// Counter return the counter, it panic when there is an error.
+func (h *CounterHandle) Counter() prometheus.Counter {
+	m, err := h.Metric()
+	if err != nil {
+		panic(err)
+	}
+	return m.(prometheus.Counter)
+}
+
+// Inc increment the counter by one.
+func (h *CounterHandle) Inc() {
+	h.Counter().Inc()
+}
+
+// Add add val to the counter.
+func (h *CounterHandle) Add(val float64) {
+	h.Counter().Add(val)
+}
+
+// GaugeHandle is Handle of a gauge.
+type GaugeHandle struct {
+	*Handle
+}
+
+// Handle return GaugeHandle of gauge with labels.
+func (g *Gauge) Handle(labels prometheus.Labels) *GaugeHandle {
+	return &GaugeHandle{g.Vector.Handle(labels)}
+}
+
+// Gauge return the gauge, it panic when there is an error.
+func (h *GaugeHandle) Gauge() prometheus.Gauge {
+	m, err := h.Metric()
+	if err != nil {
+		panic(err)
+	}
+	return m.(prometheus.Gauge)
+}
+
+// Set set the gauge to val.
+func (h *GaugeHandle) Set(val float64) {
+	h.Gauge().Set(val)
+}
+
+// Inc increment the gauge by one.
+func (h *GaugeHandle) Inc() {
+	h.Gauge().Inc()
+}
+
+// Dec decrement the gauge by one.
+func (h *GaugeHandle) Dec() {
+	h.Gauge().Dec()
+}
+
+// Add add val to the gauge.
+func (h *GaugeHandle) Add(val float64) {
+	h.Gauge().Add(val)
+}
+
+// Sub subtract val from the gauge.
+func (h *GaugeHandle) Sub(val float64) {
+	h.Gauge().Sub(val)
+}
+
+// HistogramHandle is Handle of a histogram.
+type HistogramHandle struct {
+	*Handle
+}
+
+// Handle return HistogramHandle of histogram with labels.
+func (h *Histogram) Handle(labels prometheus.Labels) *HistogramHandle {
+	return &HistogramHandle{h.Vector.Handle(labels)}
+}
+
+// Histogram return the histogram, it panic when there is an error.
+func (h *HistogramHandle) Histogram() prometheus.Histogram {
+	m, err := h.Metric()
+	if err != nil {
+		panic(err)
+	}
+	return m.(prometheus.Histogram)
+}
+
+// Observe add observation val to the histogram.
+func (h *HistogramHandle) Observe(val float64) {
+	h.Histogram().Observe(val)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandle_Metric(t *testing.T) {
+	cv := createCounter(0)
+	h := cv.Handle(prometheus.Labels{"label1": "a"})
+	assert.Equal(t, 0, cv.Length())
+
+	h.Inc()
+	h.Add(2)
+	assert.Equal(t, 1, cv.Length())
+	assert.Equal(t, cv.With(prometheus.Labels{"label1": "a"}), h.Counter())
+
+	// new label key does not break the handle.
+	cv.With(prometheus.Labels{"label2": "b"}).Inc()
+	h.Inc()
+
+	series, _ := cv.Series()
+	assert.Equal(t, 2, len(series))
+	assert.Equal(t, 4.0, series[0].Metric.GetCounter().GetValue())
+}
+
+func TestHandle_GC(t *testing.T) {
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "gauge_vector", Help: "help", Expire: time.Millisecond})
+	h := gv.Handle(prometheus.Labels{"label1": "a"})
+	h.Set(5)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, gv.GC().Deleted)
+	assert.Equal(t, 0, gv.Length())
+
+	h.Inc()
+	series, _ := gv.Series()
+	if assert.Equal(t, 1, len(series)) {
+		assert.Equal(t, 1.0, series[0].Metric.GetGauge().GetValue())
+	}
+
+	gv.Reset()
+	h.Dec()
+	series, _ = gv.Series()
+	if assert.Equal(t, 1, len(series)) {
+		assert.Equal(t, -1.0, series[0].Metric.GetGauge().GetValue())
+	}
+}
+
+func TestHandle_Error(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "h", Help: "help", Buckets: []float64{1}, MaxLength: 1})
+	hv.With(prometheus.Labels{"label1": "a"})
+	hv.With(prometheus.Labels{"label1": "b"})
+
+	h := hv.Handle(prometheus.Labels{"label1": "c"})
+	_, err := h.Metric()
+	assert.Error(t, err)
+	assert.Panics(t, func() { h.Observe(1) })
+}
+
+func TestHandle_Allocs(t *testing.T) {
+	cv := createCounter(0)
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "gauge_vector", Help: "help"})
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "h", Help: "help", Buckets: prometheus.DefBuckets})
+	labels := prometheus.Labels{"label1": "a", "label2": "b"}
+	ch, gh, hh := cv.Handle(labels), gv.Handle(labels), hv.Handle(labels)
+	ch.Inc()
+	gh.Inc()
+	hh.Observe(1)
+
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		ch.Inc()
+		gh.Add(1)
+		hh.Observe(1)
+	}))
+}
+
+func BenchmarkCounter_With(b *testing.B) {
+	cv := createCounter(0)
+	labels := prometheus.Labels{"label1": "a", "label2": "b"}
+	cv.With(labels).Inc()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cv.With(prometheus.Labels{"label1": "a", "label2": "b"}).Inc()
+	}
+}
+
+func BenchmarkCounter_WithLabelValues(b *testing.B) {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "c", Help: "help", LabelNames: []string{"label1", "label2"}})
+	cv.WithLabelValues("a", "b").Inc()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cv.WithLabelValues("a", "b").Inc()
+	}
+}
+
+func BenchmarkCounterHandle_Inc(b *testing.B) {
+	cv := createCounter(0)
+	h := cv.Handle(prometheus.Labels{"label1": "a", "label2": "b"})
+	h.Inc()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Inc()
+	}
+}
+
+func BenchmarkHistogramHandle_Observe(b *testing.B) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "h", Help: "help", Buckets: prometheus.DefBuckets})
+	h := hv.Handle(prometheus.Labels{"label1": "a", "label2": "b"})
+	h.Observe(1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Observe(1)
+	}
+}
@@ -8,6 +8,7 @@ package dynamicvector
 import (
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -39,6 +40,108 @@ func (h *Histogram) With(labels prometheus.Labels) prometheus.Histogram {
 	return h.Vector.With(labels).(prometheus.Histogram)
 }
 
+// GetMetricWithLabelValues is a syntatic sugar for Vector.GetMetricWithLabelValues
+func (h *Histogram) GetMetricWithLabelValues(values ...string) (prometheus.Histogram, error) {
+	metric, err := h.Vector.GetMetricWithLabelValues(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.(prometheus.Histogram), nil
+}
+
+// WithLabelValues is a syntatic sugar for Vector.WithLabelValues
+func (h *Histogram) WithLabelValues(values ...string) prometheus.Histogram {
+	return h.Vector.WithLabelValues(values...).(prometheus.Histogram)
+}
+
+// GetMetricWithStruct is a syntatic sugar for Vector.GetMetricWithStruct
+func (h *Histogram) GetMetricWithStruct(s interface{}) (prometheus.Histogram, error) {
+	metric, err := h.Vector.GetMetricWithStruct(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return metric.(prometheus.Histogram), nil
+}
+
+// WithStruct is a syntatic sugar for Vector.WithStruct
+func (h *Histogram) WithStruct(s interface{}) prometheus.Histogram {
+	return h.Vector.WithStruct(s).(prometheus.Histogram)
+}
+
+// CurryWith return a view of this histogram with labels bound, see Vector.CurryWith.
+func (h *Histogram) CurryWith(labels prometheus.Labels) (*Histogram, error) {
+	vec, err := h.Vector.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Histogram{vec}, nil
+}
+
+// MustCurryWith behave like CurryWith except it will panic instead when there is an error.
+func (h *Histogram) MustCurryWith(labels prometheus.Labels) *Histogram {
+	return &Histogram{h.Vector.MustCurryWith(labels)}
+}
+
+// ObserverVec return this histogram as prometheus.ObserverVec, so it can be used with promhttp
+// instrumentation. For promhttp, declare "code" and "method" in Opts.LabelNames and do not set
+// Opts.Unchecked.
+func (h *Histogram) ObserverVec() prometheus.ObserverVec {
+	return observerVec{h: h}
+}
+
+// observerVec adapt Histogram to prometheus.ObserverVec.
+type observerVec struct {
+	h *Histogram
+}
+
+func (o observerVec) Describe(ch chan<- *prometheus.Desc) {
+	o.h.Describe(ch)
+}
+
+func (o observerVec) Collect(ch chan<- prometheus.Metric) {
+	o.h.Collect(ch)
+}
+
+func (o observerVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	m, err := o.h.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (o observerVec) GetMetricWithLabelValues(values ...string) (prometheus.Observer, error) {
+	m, err := o.h.GetMetricWithLabelValues(values...)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (o observerVec) With(labels prometheus.Labels) prometheus.Observer {
+	return o.h.With(labels)
+}
+
+func (o observerVec) WithLabelValues(values ...string) prometheus.Observer {
+	return o.h.WithLabelValues(values...)
+}
+
+func (o observerVec) CurryWith(labels prometheus.Labels) (prometheus.ObserverVec, error) {
+	h, err := o.h.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return h.ObserverVec(), nil
+}
+
+func (o observerVec) MustCurryWith(labels prometheus.Labels) prometheus.ObserverVec {
+	return o.h.MustCurryWith(labels).ObserverVec()
+}
+
+// HistogramUnit implement prometheus.Histogram and Metric
 type HistogramUnit struct {
 	sum     float64
 	count   uint64
@@ -46,6 +149,7 @@ type HistogramUnit struct {
 	vec     *Vector
 	labels  []string
 	last    time.Time
+	created time.Time
 
 	mtx sync.RWMutex
 }
@@ -53,20 +157,22 @@ type HistogramUnit struct {
 // NewHistogramUnit will create new hitogram with specified label values.
 func NewHistogramUnit(vec *Vector, labelValues []string) Metric {
 	b := make(map[float64]uint64)
-	for _, v := range vec.opts.Buckets {
+	for _, v := range vec.meta().opts.Buckets {
 		b[v] = 0
 	}
 
+	now := time.Now()
 	return &HistogramUnit{
 		vec:     vec,
 		labels:  labelValues,
-		last:    time.Now(),
+		last:    now,
+		created: now,
 		buckets: b,
 	}
 }
 
 func (u *HistogramUnit) Desc() *prometheus.Desc {
-	return u.vec.desc
+	return u.vec.MetricDesc(u.labels)
 }
 
 func (u *HistogramUnit) Write(metric *dto.Metric) error {
@@ -78,14 +184,14 @@ func (u *HistogramUnit) Write(metric *dto.Metric) error {
 		buckets = append(buckets, &dto.Bucket{CumulativeCount: proto.Uint64(count), UpperBound: proto.Float64(bound)})
 	}
 
-	metric.Label = labelsToProto(u.vec.labels.ValuesToPromLabels(u.labels))
+	metric.Label = u.vec.MetricLabels(u.labels)
 	metric.Histogram = &dto.Histogram{SampleCount: proto.Uint64(u.count), SampleSum: proto.Float64(u.sum), Bucket: buckets}
 
 	return nil
 }
 
 func (u *HistogramUnit) Describe(ch chan<- *prometheus.Desc) {
-	ch <- u.vec.desc
+	ch <- u.Desc()
 }
 
 func (u *HistogramUnit) Collect(ch chan<- prometheus.Metric) {
@@ -107,5 +213,21 @@ func (u *HistogramUnit) Observe(v float64) {
 }
 
 func (u *HistogramUnit) LastEdit() time.Time {
+	u.mtx.RLock()
+	defer u.mtx.RUnlock()
+
 	return u.last
 }
+
+// Created return the time when this metric is created.
+func (u *HistogramUnit) Created() time.Time {
+	return u.created
+}
+
+// MemorySize implement MemorySizer.
+func (u *HistogramUnit) MemorySize() int {
+	u.mtx.RLock()
+	defer u.mtx.RUnlock()
+
+	return int(unsafe.Sizeof(*u)) + LabelValuesSize(u.labels) + len(u.buckets)*bucketSize
+}
@@ -6,9 +6,12 @@
 package dynamicvector_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/rolandhawk/dynamicvector"
 	"github.com/stretchr/testify/assert"
@@ -39,6 +42,18 @@ func TestHistogram_With(t *testing.T) {
 	v.With(prometheus.Labels{"label1": "value1"})
 }
 
+func TestHistogram_WithLabelValues(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "vector", Help: "help", LabelNames: []string{"a", "b"}})
+
+	hv.WithLabelValues("1", "2").Observe(1)
+	m, err := hv.GetMetricWith(prometheus.Labels{"a": "1", "b": "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, m, hv.WithLabelValues("1", "2"))
+
+	_, err = hv.GetMetricWithLabelValues("1")
+	assert.Error(t, err)
+}
+
 func TestHistogramUnit_Desc(t *testing.T) {
 	v := createHistogram(0)
 	histogram := v.With(prometheus.Labels{"label1": "value1"})
@@ -122,3 +137,38 @@ func createHistogram(ml int) *dynamicvector.Histogram {
 		MaxLength:   ml,
 	})
 }
+
+func TestHistogram_InstrumentHandlerDuration(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{
+		Name:       "request_duration_seconds",
+		Buckets:    []float64{1, 10},
+		LabelNames: []string{"code", "method"},
+	})
+	h := promhttp.InstrumentHandlerDuration(hv.ObserverVec(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, 2, hv.Length())
+
+	var m dto.Metric
+	assert.NoError(t, hv.WithLabelValues("418", "get").Write(&m))
+	assert.Equal(t, uint64(2), m.Histogram.GetSampleCount())
+}
+
+func TestHistogram_CurryWith_ObserverVec(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{
+		Name:       "request_duration_seconds",
+		Buckets:    []float64{1, 10},
+		LabelNames: []string{"handler", "code", "method"},
+	})
+	h := promhttp.InstrumentHandlerDuration(hv.ObserverVec().MustCurryWith(prometheus.Labels{"handler": "index"}), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	_, err := hv.GetMetricWithLabelValues("index", "200", "get")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hv.Length())
+}
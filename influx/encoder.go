@@ -0,0 +1,146 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package influx export dynamicvector metrics in InfluxDB line protocol.
+package influx
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+)
+
+// Encoder write vectors in InfluxDB line protocol. Measurement is the fully-qualified name of vector,
+// tags are dynamic and constant labels. Counter, gauge and untyped have single value field. Histogram
+// and summary have count and sum fields, histogram also have one field per bucket upper bound.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder will create new Encoder that write to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode write all live metrics in vector with timestamp ts.
+func (e *Encoder) Encode(v *dynamicvector.Vector, ts time.Time) error {
+	lines, err := vectorLines(nil, v, ts)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if _, err := e.w.Write(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// vectorLines append lines of all live metrics in vector to lines.
+func vectorLines(lines [][]byte, v *dynamicvector.Vector, ts time.Time) ([][]byte, error) {
+	series, err := v.Series()
+	if err != nil {
+		return nil, err
+	}
+
+	measurement := measurementEscaper.Replace(v.Name())
+	for _, s := range series {
+		if line := appendLine(nil, measurement, s, ts); line != nil {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+// appendLine append a single line to b. It return nil when series has no field to write.
+func appendLine(b []byte, measurement string, s dynamicvector.Series, ts time.Time) []byte {
+	fields := seriesFields(s.Metric)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	b = append(b, measurement...)
+
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b = append(b, ',')
+		b = append(b, keyEscaper.Replace(name)...)
+		b = append(b, '=')
+		b = append(b, keyEscaper.Replace(s.Labels[name])...)
+	}
+
+	for i, f := range fields {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ',')
+		}
+		b = append(b, keyEscaper.Replace(f.key)...)
+		b = append(b, '=')
+		b = strconv.AppendFloat(b, f.value, 'g', -1, 64)
+	}
+
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, ts.UnixNano(), 10)
+
+	return append(b, '\n')
+}
+
+type field struct {
+	key   string
+	value float64
+}
+
+// seriesFields return fields for metric. NaN and infinite value are skipped since line protocol
+// can not represent them.
+func seriesFields(m *dto.Metric) []field {
+	var fields []field
+	switch {
+	case m.Counter != nil:
+		fields = append(fields, field{"value", m.Counter.GetValue()})
+	case m.Gauge != nil:
+		fields = append(fields, field{"value", m.Gauge.GetValue()})
+	case m.Untyped != nil:
+		fields = append(fields, field{"value", m.Untyped.GetValue()})
+	case m.Histogram != nil:
+		fields = append(fields, field{"count", float64(m.Histogram.GetSampleCount())}, field{"sum", m.Histogram.GetSampleSum()})
+
+		buckets := append([]*dto.Bucket(nil), m.Histogram.Bucket...)
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].GetUpperBound() < buckets[j].GetUpperBound() })
+		for _, b := range buckets {
+			fields = append(fields, field{strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64), float64(b.GetCumulativeCount())})
+		}
+		fields = append(fields, field{"+Inf", float64(m.Histogram.GetSampleCount())})
+	case m.Summary != nil:
+		fields = append(fields, field{"count", float64(m.Summary.GetSampleCount())}, field{"sum", m.Summary.GetSampleSum()})
+	}
+
+	valid := fields[:0]
+	for _, f := range fields {
+		if !math.IsNaN(f.value) && !math.IsInf(f.value, 0) {
+			valid = append(valid, f)
+		}
+	}
+
+	return valid
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/influx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder_Encode_Counter(t *testing.T) {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Namespace:   "app",
+		Name:        "requests_total",
+		ConstLabels: prometheus.Labels{"env": "prod"},
+	})
+	cv.With(prometheus.Labels{"path": "/a b", "code": "200"}).Add(3)
+	cv.With(prometheus.Labels{"path": "/x,y=z"}).Inc()
+
+	var buf bytes.Buffer
+	err := influx.NewEncoder(&buf).Encode(cv.Vector, time.Unix(1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "app_requests_total,code=200,env=prod,path=/a\\ b value=3 1000000000\n"+
+		"app_requests_total,env=prod,path=/x\\,y\\=z value=1 1000000000\n", buf.String())
+}
+
+func TestEncoder_Encode_Histogram(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "latency", Buckets: []float64{1, 10, 0.5}})
+	hv.With(prometheus.Labels{"path": "/"}).Observe(2)
+
+	var buf bytes.Buffer
+	err := influx.NewEncoder(&buf).Encode(hv.Vector, time.Unix(0, 5))
+	assert.NoError(t, err)
+	assert.Equal(t, "latency,path=/ count=1,sum=2,0.5=0,1=0,10=1,+Inf=1 5\n", buf.String())
+}
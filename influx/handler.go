@@ -0,0 +1,191 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/internal/bridge"
+)
+
+// FieldType is the metric type that a field become.
+type FieldType int
+
+// Possible FieldType.
+const (
+	Gauge FieldType = iota
+	Counter
+)
+
+// HandlerOpts is an option for creating Handler.
+type HandlerOpts struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	// FieldTypes set metric type by metric name, which is measurement_field, or measurement if
+	// the field is named value. Metric that is not listed become Gauge.
+	FieldTypes map[string]FieldType
+
+	// Expire and MaxLength are applied to every created vector, see dynamicvector.Opts.
+	Expire    time.Duration
+	MaxLength int
+}
+
+// Handler is http.Handler compatible with InfluxDB /write API. Every field of a point become a
+// series in dynamic vector named measurement_field with tags as labels. Vectors are created and
+// registered on demand. Counter field hold cumulative value, so counter is reset when it decrease.
+type Handler struct {
+	opts   HandlerOpts
+	family *dynamicvector.Family
+
+	mtx sync.Mutex // serialize counter read and update
+}
+
+// NewHandler will create new Handler that register created vectors to reg.
+func NewHandler(opts HandlerOpts, reg prometheus.Registerer) *Handler {
+	family := dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Default: dynamicvector.Opts{
+			Namespace: opts.Namespace,
+			Expire:    opts.Expire,
+			MaxLength: opts.MaxLength,
+			Unchecked: true,
+		},
+		Customize: func(typ string, o *dynamicvector.Opts) {
+			o.Help = fmt.Sprintf("InfluxDB field %s", o.Name)
+		},
+	}, reg)
+
+	return &Handler{opts: opts, family: family}
+}
+
+// ServeHTTP implement http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var errs []string
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := h.Write(line); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		writeError(w, http.StatusBadRequest, "partial write: "+strings.Join(errs, "; "))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Write parse and apply a single line.
+func (h *Handler) Write(line string) error {
+	p, err := ParseLine(line)
+	if err != nil {
+		return err
+	}
+
+	lbl := make(prometheus.Labels, len(p.Tags))
+	for k, v := range p.Tags {
+		lbl[bridge.SanitizeLabel(k)] = v
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for field, value := range p.Fields {
+		name := bridge.SanitizeName(p.Measurement)
+		if field != "value" {
+			name += "_" + bridge.SanitizeLabel(field)
+		}
+
+		if err := h.set(name, lbl, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (h *Handler) Vectors() []*dynamicvector.Vector {
+	return h.family.Vectors()
+}
+
+func (h *Handler) set(name string, lbl prometheus.Labels, value float64) error {
+	if h.opts.FieldTypes[name] != Counter {
+		g, err := h.family.Gauge(name, lbl)
+		if err != nil {
+			return err
+		}
+		g.Set(value)
+		return nil
+	}
+
+	cv, err := h.family.CounterVec(name)
+	if err != nil {
+		return err
+	}
+	c, err := cv.GetMetricWith(lbl)
+	if err != nil {
+		return err
+	}
+
+	var m dto.Metric
+	c.Write(&m)
+	if cur := m.Counter.GetValue(); value >= cur {
+		c.Add(value - cur)
+		return nil
+	}
+
+	// counter is reset.
+	cv.Delete(lbl)
+	if c, err = cv.GetMetricWith(lbl); err != nil {
+		return err
+	}
+	c.Add(value)
+	return nil
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Influxdb-Error", msg)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
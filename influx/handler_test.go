@@ -0,0 +1,95 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/influx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	h := influx.NewHandler(influx.HandlerOpts{
+		Namespace:  "telegraf",
+		FieldTypes: map[string]influx.FieldType{"net_bytes_recv": influx.Counter},
+	}, reg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/write?db=telegraf", strings.NewReader(
+		"cpu,host=a usage_idle=90,usage_user=10 1\n"+
+			"cpu,host=b,cpu=cpu0 usage_idle=80\n"+
+			"net,host=a bytes_recv=100i,info=\"eth0\"\n"+
+			"\n"+
+			"temperature value=21.5\n")))
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	mfs := gather(t, reg)
+	assert.Equal(t, 2, len(mfs["telegraf_cpu_usage_idle"].Metric))
+	assert.Equal(t, 1, len(mfs["telegraf_cpu_usage_user"].Metric))
+	assert.Equal(t, float64(21.5), mfs["telegraf_temperature"].Metric[0].Gauge.GetValue())
+	assert.Equal(t, float64(100), mfs["telegraf_net_bytes_recv"].Metric[0].Counter.GetValue())
+
+	// counter is cumulative, and reset when it decrease.
+	assert.NoError(t, h.Write("net,host=a bytes_recv=150i"))
+	assert.Equal(t, float64(150), gather(t, reg)["telegraf_net_bytes_recv"].Metric[0].Counter.GetValue())
+	assert.NoError(t, h.Write("net,host=a bytes_recv=20i"))
+	assert.Equal(t, float64(20), gather(t, reg)["telegraf_net_bytes_recv"].Metric[0].Counter.GetValue())
+
+	assert.Equal(t, 4, len(h.Vectors()))
+}
+
+func TestHandler_Gzip(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := influx.NewHandler(influx.HandlerOpts{}, reg)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("mem,host=a used=1\n"))
+	gz.Close()
+
+	req := httptest.NewRequest("POST", "/write", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, 1, len(gather(t, reg)["mem_used"].Metric))
+}
+
+func TestHandler_Error(t *testing.T) {
+	h := influx.NewHandler(influx.HandlerOpts{MaxLength: 1}, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/write", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/write", strings.NewReader("cpu\nmem value=1\nmem,host=a value=1\nmem,host=b,cpu=0 value=1\n")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "partial write")
+	assert.Contains(t, rec.Body.String(), "exceed length limit")
+	assert.Equal(t, 1, len(h.Vectors()))
+}
+
+func gather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	mfs, err := g.Gather()
+	assert.NoError(t, err)
+
+	res := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf
+	}
+
+	return res
+}
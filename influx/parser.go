@@ -0,0 +1,136 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Point is a single parsed line protocol line.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+
+	// Fields contain numeric and boolean fields. Boolean is converted to 1 or 0, string fields are
+	// skipped since they can not be a metric.
+	Fields map[string]float64
+
+	// Timestamp in line, zero if not set.
+	Timestamp int64
+}
+
+// ParseLine will parse a single line protocol line, for example
+// cpu,host=a usage_idle=90.5,usage_user=9i 1465839830100400200.
+func ParseLine(line string) (Point, error) {
+	p := Point{Tags: make(map[string]string), Fields: make(map[string]float64)}
+
+	sections := split(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return p, fmt.Errorf("influx: invalid line %q", line)
+	}
+
+	keys := split(sections[0], ',', false)
+	p.Measurement = unescape(keys[0])
+	if p.Measurement == "" {
+		return p, fmt.Errorf("influx: invalid line %q: missing measurement", line)
+	}
+	for _, tag := range keys[1:] {
+		kv := split(tag, '=', false)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return p, fmt.Errorf("influx: invalid line %q: invalid tag %q", line, tag)
+		}
+		p.Tags[unescape(kv[0])] = unescape(kv[1])
+	}
+
+	for _, field := range split(sections[1], ',', true) {
+		kv := split(field, '=', true)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return p, fmt.Errorf("influx: invalid line %q: invalid field %q", line, field)
+		}
+
+		v, ok, err := parseFieldValue(kv[1])
+		if err != nil {
+			return p, fmt.Errorf("influx: invalid line %q: %s", line, err)
+		}
+		if ok {
+			p.Fields[unescape(kv[0])] = v
+		}
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("influx: invalid line %q: invalid timestamp", line)
+		}
+		p.Timestamp = ts
+	}
+
+	return p, nil
+}
+
+// parseFieldValue parse field value. It return false for string field.
+func parseFieldValue(s string) (float64, bool, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return 0, false, fmt.Errorf("unterminated string %s", s)
+		}
+		return 0, false, nil
+	case s == "t" || s == "T" || s == "true" || s == "True" || s == "TRUE":
+		return 1, true, nil
+	case s == "f" || s == "F" || s == "false" || s == "False" || s == "FALSE":
+		return 0, true, nil
+	case strings.HasSuffix(s, "i"):
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		return float64(v), err == nil, err
+	case strings.HasSuffix(s, "u"):
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		return float64(v), err == nil, err
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil, err
+}
+
+// split split s by unescaped sep. If quoted is true, sep inside double quote is ignored.
+func split(s string, sep byte, quoted bool) []string {
+	var (
+		parts   []string
+		start   int
+		inQuote bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quoted && s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b = append(b, s[i])
+	}
+
+	return string(b)
+}
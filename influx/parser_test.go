@@ -0,0 +1,47 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx_test
+
+import (
+	"testing"
+
+	"github.com/rolandhawk/dynamicvector/influx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	p, err := influx.ParseLine(`cpu\ load,host=a\,b,region=us\ west idle=90.5,user=9i,up=t,name="x y,z=1",free=3u 1465839830100400200`)
+	assert.NoError(t, err)
+	assert.Equal(t, influx.Point{
+		Measurement: "cpu load",
+		Tags:        map[string]string{"host": "a,b", "region": "us west"},
+		Fields:      map[string]float64{"idle": 90.5, "user": 9, "up": 1, "free": 3},
+		Timestamp:   1465839830100400200,
+	}, p)
+
+	p, err = influx.ParseLine(`mem value=1`)
+	assert.NoError(t, err)
+	assert.Equal(t, "mem", p.Measurement)
+	assert.Equal(t, map[string]float64{"value": 1}, p.Fields)
+	assert.Equal(t, int64(0), p.Timestamp)
+}
+
+func TestParseLine_Error(t *testing.T) {
+	for _, line := range []string{
+		"cpu",
+		",host=a value=1",
+		"cpu,host value=1",
+		"cpu,host= value=1",
+		"cpu value",
+		"cpu value=x",
+		`cpu value="x`,
+		"cpu value=1 x",
+		"cpu value=1 1 1",
+	} {
+		_, err := influx.ParseLine(line)
+		assert.Error(t, err, line)
+	}
+}
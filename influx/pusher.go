@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// MaxUDPPayload is the maximum size of a single UDP datagram sent by Pusher.
+const MaxUDPPayload = 1400
+
+// Pusher periodically push vectors to InfluxDB, either to HTTP /write endpoint or UDP listener.
+type Pusher struct {
+	// ErrorHandler is called when push in Run fail. Nil means error is ignored.
+	ErrorHandler func(error)
+
+	vectors dynamicvector.VectorLister
+	send    func(lines [][]byte) error
+	closer  io.Closer // connection of UDP pusher, nil for HTTP pusher.
+}
+
+// NewHTTPPusher will create Pusher that POST line protocol to url, for example
+// http://localhost:8086/write?db=metrics. If client is nil, http.DefaultClient is used.
+func NewHTTPPusher(url string, client *http.Client, vl dynamicvector.VectorLister) *Pusher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Pusher{
+		vectors: vl,
+		send: func(lines [][]byte) error {
+			res, err := client.Post(url, "text/plain; charset=utf-8", bytes.NewReader(bytes.Join(lines, nil)))
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode/100 != 2 {
+				body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+				return fmt.Errorf("influx: unexpected status %s: %s", res.Status, bytes.TrimSpace(body))
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			return nil
+		},
+	}
+}
+
+// NewUDPPusher will create Pusher that send line protocol to UDP addr. Lines are batched into
+// datagrams no bigger than MaxUDPPayload, except a single line that is already bigger.
+func NewUDPPusher(addr string, vl dynamicvector.VectorLister) (*Pusher, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pusher{
+		vectors: vl,
+		closer:  conn,
+		send: func(lines [][]byte) error {
+			var buf []byte
+			for _, line := range lines {
+				if len(buf) > 0 && len(buf)+len(line) > MaxUDPPayload {
+					if _, err := conn.Write(buf); err != nil {
+						return err
+					}
+					buf = buf[:0]
+				}
+				buf = append(buf, line...)
+			}
+
+			if len(buf) > 0 {
+				_, err := conn.Write(buf)
+				return err
+			}
+			return nil
+		},
+	}, nil
+}
+
+// Push send all live metrics once.
+func (p *Pusher) Push() error {
+	var (
+		lines [][]byte
+		err   error
+	)
+	ts := time.Now()
+	for _, v := range p.vectors.Vectors() {
+		if lines, err = vectorLines(lines, v, ts); err != nil {
+			return err
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	return p.send(lines)
+}
+
+// Run will push every interval until ctx is done.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Push(); err != nil && p.ErrorHandler != nil {
+				p.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// Close close connection of UDP pusher. It does nothing for HTTP pusher.
+func (p *Pusher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package influx_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/influx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPusher_HTTP(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/write", r.URL.Path)
+		assert.Equal(t, "metrics", r.URL.Query().Get("db"))
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "temperature"})
+	gv.With(prometheus.Labels{"room": "a"}).Set(21.5)
+
+	p := influx.NewHTTPPusher(srv.URL+"/write?db=metrics", nil, dynamicvector.VectorList{gv.Vector})
+	assert.NoError(t, p.Push())
+	assert.True(t, strings.HasPrefix(body, "temperature,room=a value=21.5 "), body)
+	assert.NoError(t, p.Close())
+}
+
+func TestPusher_HTTP_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "temperature"})
+	gv.With(prometheus.Labels{"room": "a"}).Set(21.5)
+
+	p := influx.NewHTTPPusher(srv.URL+"/write?db=metrics", nil, dynamicvector.VectorList{gv.Vector})
+	err := p.Push()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "database not found")
+}
+
+func TestPusher_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "events_total"})
+	for i := 0; i < 100; i++ {
+		cv.With(prometheus.Labels{"id": fmt.Sprintf("%03d", i)}).Inc()
+	}
+
+	p, err := influx.NewUDPPusher(conn.LocalAddr().String(), dynamicvector.VectorList{cv.Vector})
+	assert.NoError(t, err)
+	assert.NoError(t, p.Push())
+
+	var lines []string
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(lines) < 100 {
+		n, _, err := conn.ReadFrom(buf)
+		assert.NoError(t, err)
+		assert.True(t, n <= influx.MaxUDPPayload)
+		lines = append(lines, strings.Split(strings.TrimSpace(string(buf[:n])), "\n")...)
+	}
+	assert.Equal(t, 100, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "events_total,id=000 value=1 "))
+
+	assert.NoError(t, p.Close())
+	assert.Error(t, p.Push())
+}
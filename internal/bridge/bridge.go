@@ -0,0 +1,29 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package bridge contain helpers shared by packages that feed other protocols into dynamic vectors.
+package bridge
+
+// SanitizeName replace characters that are not allowed in prometheus metric name with '_'.
+func SanitizeName(s string) string {
+	return sanitize(s, true)
+}
+
+// SanitizeLabel replace characters that are not allowed in prometheus label name with '_'.
+func SanitizeLabel(s string) string {
+	return sanitize(s, false)
+}
+
+func sanitize(s string, colon bool) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || colon && c == ':' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+
+	return string(b)
+}
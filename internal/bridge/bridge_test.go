@@ -0,0 +1,18 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package bridge_test
+
+import (
+	"testing"
+
+	"github.com/rolandhawk/dynamicvector/internal/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "_xx_cpu:load_1", bridge.SanitizeName("1xx.cpu:load-1"))
+	assert.Equal(t, "_xx_cpu_load_1", bridge.SanitizeLabel("1xx.cpu:load-1"))
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package bridge
+
+import (
+	"io"
+	"sync"
+)
+
+// Closers keep track of listeners and connections of a server so they can be closed together.
+// Zero value is ready to use.
+type Closers struct {
+	mtx     sync.Mutex
+	closers map[io.Closer]struct{}
+}
+
+// Track add or remove listener or connection that is closed by Close.
+func (c *Closers) Track(closer io.Closer, add bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !add {
+		delete(c.closers, closer)
+		return
+	}
+
+	if c.closers == nil {
+		c.closers = make(map[io.Closer]struct{})
+	}
+	c.closers[closer] = struct{}{}
+}
+
+// Close close all tracked listeners and connections and return the first error.
+func (c *Closers) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var err error
+	for closer := range c.closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	c.closers = nil
+
+	return err
+}
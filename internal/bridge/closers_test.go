@@ -0,0 +1,40 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package bridge_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rolandhawk/dynamicvector/internal/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloser(t *testing.T) {
+	var c bridge.Closers
+	a, b := &closer{}, &closer{err: errors.New("fail")}
+
+	c.Track(a, true)
+	c.Track(b, true)
+	c.Track(b, false)
+	assert.NoError(t, c.Close())
+	assert.True(t, a.closed)
+	assert.False(t, b.closed)
+
+	c.Track(b, true)
+	assert.Error(t, c.Close())
+	assert.NoError(t, c.Close())
+}
+
+type closer struct {
+	closed bool
+	err    error
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return c.err
+}
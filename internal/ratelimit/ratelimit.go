@@ -0,0 +1,113 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package ratelimit contain token bucket rate limiter shared by dynamicvector and its ingestion
+// packages.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket rate limiter. It is not safe for concurrent use.
+type TokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket will create full TokenBucket that refill rate tokens per second up to burst. Zero
+// burst means rate, and burst is at least one.
+func NewTokenBucket(rate float64, burst int, now time.Time) *TokenBucket {
+	if burst <= 0 {
+		burst = int(rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// Allow refill the bucket and return whether a token is available.
+func (b *TokenBucket) Allow(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+// Take remove a token, Allow must be called before it.
+func (b *TokenBucket) Take() {
+	b.tokens--
+}
+
+// TakeN refill the bucket, take at most n tokens and return number of tokens taken.
+func (b *TokenBucket) TakeN(now time.Time, n int) int {
+	b.refill(now)
+
+	taken := n
+	if float64(taken) > b.tokens {
+		taken = int(b.tokens)
+	}
+	b.tokens -= float64(taken)
+
+	return taken
+}
+
+// Full return whether the bucket is full at now, which is the same as a new bucket.
+func (b *TokenBucket) Full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// Clients is a TokenBucket per client. It is safe for concurrent use.
+type Clients struct {
+	rate  float64
+	burst int
+
+	mtx     sync.Mutex
+	buckets map[string]*TokenBucket
+}
+
+// NewClients will create Clients whose buckets are made with NewTokenBucket(rate, burst).
+func NewClients(rate float64, burst int) *Clients {
+	return &Clients{rate: rate, burst: burst, buckets: make(map[string]*TokenBucket)}
+}
+
+// TakeN take at most n tokens for client and return number of tokens taken.
+func (c *Clients) TakeN(client string, n int) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := time.Now()
+	b, ok := c.buckets[client]
+	if !ok {
+		b = NewTokenBucket(c.rate, c.burst, now)
+		c.buckets[client] = b
+	}
+
+	return b.TakeN(now, n)
+}
+
+// Cleanup remove clients whose bucket is already full, they are equal to new client.
+func (c *Clients) Cleanup() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := time.Now()
+	for client, b := range c.buckets {
+		if b.Full(now) {
+			delete(c.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rolandhawk/dynamicvector/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := ratelimit.NewTokenBucket(2, 2, now)
+
+	assert.True(t, b.Allow(now))
+	b.Take()
+	assert.True(t, b.Allow(now))
+	b.Take()
+	assert.False(t, b.Allow(now))
+
+	// half second refill one token.
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, b.Allow(now))
+	assert.False(t, b.Full(now))
+	assert.True(t, b.Full(now.Add(time.Second)))
+}
+
+func TestTokenBucket_TakeN(t *testing.T) {
+	now := time.Now()
+	b := ratelimit.NewTokenBucket(1, 3, now)
+
+	assert.Equal(t, 2, b.TakeN(now, 2))
+	assert.Equal(t, 1, b.TakeN(now, 2))
+	assert.Equal(t, 0, b.TakeN(now, 2))
+	assert.Equal(t, 1, b.TakeN(now.Add(time.Second), 2))
+}
+
+func TestClients(t *testing.T) {
+	c := ratelimit.NewClients(1, 2)
+
+	assert.Equal(t, 2, c.TakeN("a", 5))
+	assert.Equal(t, 0, c.TakeN("a", 1))
+	assert.Equal(t, 1, c.TakeN("b", 1))
+	c.Cleanup()
+	assert.Equal(t, 0, c.TakeN("a", 1))
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// VectorLister give list of vectors. It is used by handlers to find vectors to expose.
+type VectorLister interface {
+	Vectors() []*Vector
+}
+
+// VectorList is a static VectorLister.
+type VectorList []*Vector
+
+// Vectors implement VectorLister.
+func (l VectorList) Vectors() []*Vector {
+	return l
+}
+
+type jsonVector struct {
+	Name   string       `json:"name"`
+	Help   string       `json:"help"`
+	Total  int          `json:"total"`
+	Series []jsonSeries `json:"series"`
+}
+
+type jsonSeries struct {
+	Labels    prometheus.Labels `json:"labels"`
+	Type      string            `json:"type"`
+	Value     *float64          `json:"value,omitempty"`
+	Count     *uint64           `json:"count,omitempty"`
+	Sum       *float64          `json:"sum,omitempty"`
+	Buckets   []jsonBucket      `json:"buckets,omitempty"`
+	LastEdit  time.Time         `json:"last_edit"`
+	CreatedAt *time.Time        `json:"created,omitempty"`
+}
+
+type jsonBucket struct {
+	UpperBound jsonFloat `json:"le"`
+	Count      uint64    `json:"count"`
+}
+
+// jsonFloat encode float64 as string so +Inf and NaN are valid JSON.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatFloat(float64(f), 'g', -1, 64))
+}
+
+// MarshalJSON implement json.Marshaler. It dump every live metrics in vector.
+func (v *Vector) MarshalJSON() ([]byte, error) {
+	jv, err := v.toJSON(nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jv)
+}
+
+// toJSON convert vector to its json representation. Only series that match all matchers are
+// included, skipping first offset series and at most limit series. Zero limit means no limit.
+func (v *Vector) toJSON(matchers []*Matcher, offset, limit int) (jsonVector, error) {
+	series, err := v.Series(matchers...)
+	if err != nil {
+		return jsonVector{}, err
+	}
+
+	jv := jsonVector{
+		Name:   v.Name(),
+		Help:   v.Opts().Help,
+		Total:  len(series),
+		Series: []jsonSeries{},
+	}
+
+	if offset > len(series) {
+		offset = len(series)
+	}
+	series = series[offset:]
+	if limit > 0 && limit < len(series) {
+		series = series[:limit]
+	}
+
+	for _, s := range series {
+		jv.Series = append(jv.Series, newJSONSeries(s))
+	}
+
+	return jv, nil
+}
+
+func newJSONSeries(s Series) jsonSeries {
+	js := jsonSeries{
+		Labels:   s.Labels,
+		LastEdit: s.LastEdit,
+	}
+	if !s.Created.IsZero() {
+		js.CreatedAt = &s.Created
+	}
+
+	m := s.Metric
+	switch {
+	case m.Counter != nil:
+		js.Type = "counter"
+		js.Value = m.Counter.Value
+	case m.Gauge != nil:
+		js.Type = "gauge"
+		js.Value = m.Gauge.Value
+	case m.Untyped != nil:
+		js.Type = "untyped"
+		js.Value = m.Untyped.Value
+	case m.Histogram != nil:
+		js.Type = "histogram"
+		js.Count = m.Histogram.SampleCount
+		js.Sum = m.Histogram.SampleSum
+		for _, b := range m.Histogram.Bucket {
+			js.Buckets = append(js.Buckets, jsonBucket{UpperBound: jsonFloat(b.GetUpperBound()), Count: b.GetCumulativeCount()})
+		}
+		sort.Slice(js.Buckets, func(i, j int) bool { return js.Buckets[i].UpperBound < js.Buckets[j].UpperBound })
+		js.Buckets = append(js.Buckets, jsonBucket{UpperBound: jsonFloat(math.Inf(1)), Count: m.Histogram.GetSampleCount()})
+	case m.Summary != nil:
+		js.Type = "summary"
+		js.Count = m.Summary.SampleCount
+		js.Sum = m.Summary.SampleSum
+	}
+
+	return js
+}
+
+// NewJSONHandler return http.Handler that dump vectors as JSON. It is meant to be mounted at
+// /debug/vectors.json. The handler accept these query parameters:
+//   - name: only dump vector with this fully-qualified name.
+//   - match: label matcher such as method="GET" or code=~"5..". It can be repeated.
+//   - offset, limit: pagination for series in each vector.
+func NewJSONHandler(vl VectorLister) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		matchers, err := parseMatchers(q["match"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		offset, err := parseInt(q.Get("offset"))
+		if err != nil {
+			http.Error(w, "invalid offset: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, err := parseInt(q.Get("limit"))
+		if err != nil {
+			http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res := []jsonVector{}
+		for _, v := range vl.Vectors() {
+			if name := q.Get("name"); name != "" && name != v.Name() {
+				continue
+			}
+
+			jv, err := v.toJSON(matchers, offset, limit)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res = append(res, jv)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+func parseMatchers(ss []string) ([]*Matcher, error) {
+	var matchers []*Matcher
+	for _, s := range ss {
+		m, err := ParseMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
+
+func parseInt(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err == nil && i < 0 {
+		return 0, fmt.Errorf("negative value %d", i)
+	}
+	return i, err
+}
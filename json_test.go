@@ -0,0 +1,91 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_MarshalJSON(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "histogram_vector", Buckets: []float64{1, 10, 100}})
+	hv.With(prometheus.Labels{"label1": "value1"}).Observe(5)
+
+	b, err := json.Marshal(hv)
+	assert.NoError(t, err)
+
+	var res struct {
+		Name   string
+		Total  int
+		Series []struct {
+			Labels  map[string]string
+			Type    string
+			Count   uint64
+			Sum     float64
+			Buckets []struct {
+				Le    string
+				Count uint64
+			}
+			Created string
+		}
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Equal(t, "histogram_vector", res.Name)
+	assert.Equal(t, 1, res.Total)
+	assert.Equal(t, "histogram", res.Series[0].Type)
+	assert.Equal(t, map[string]string{"label1": "value1"}, res.Series[0].Labels)
+	assert.Equal(t, uint64(1), res.Series[0].Count)
+	assert.Equal(t, float64(5), res.Series[0].Sum)
+	assert.Equal(t, 4, len(res.Series[0].Buckets))
+	assert.Equal(t, "1", res.Series[0].Buckets[0].Le)
+	assert.Equal(t, "+Inf", res.Series[0].Buckets[3].Le)
+	assert.NotEmpty(t, res.Series[0].Created)
+}
+
+func TestJSONHandler(t *testing.T) {
+	cv := createCounter(0)
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "gauge_vector"})
+	for _, v := range []string{"a", "b", "c", "d"} {
+		cv.With(prometheus.Labels{"label1": v}).Inc()
+		gv.With(prometheus.Labels{"label1": v}).Set(1)
+	}
+	h := dynamicvector.NewJSONHandler(dynamicvector.VectorList{cv.Vector, gv.Vector})
+
+	var res []struct {
+		Name   string
+		Total  int
+		Series []struct {
+			Labels map[string]string
+			Value  float64
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", `/debug/vectors.json?name=counter_vector&match=label1!="a"&offset=1&limit=1`, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, 3, res[0].Total)
+	assert.Equal(t, 1, len(res[0].Series))
+	assert.Equal(t, map[string]string{"label1": "c"}, res[0].Series[0].Labels)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vectors.json", nil))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, 2, len(res))
+
+	for _, q := range []string{"match=label1", "offset=x", "limit=-1"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vectors.json?"+q, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, q)
+	}
+}
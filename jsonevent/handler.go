@@ -0,0 +1,283 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package jsonevent provide HTTP endpoint that accept metric updates as JSON events and route
+// them into dynamic vectors.
+package jsonevent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/internal/ratelimit"
+)
+
+// Event is a single metric update.
+type Event struct {
+	Metric string            `json:"metric"`
+	Type   string            `json:"type"`
+	Labels prometheus.Labels `json:"labels"`
+
+	// Value is added to counter, set to gauge or observed by histogram. Counter default to 1.
+	Value *float64 `json:"value"`
+}
+
+// Metric is allowlist entry for a single metric.
+type Metric struct {
+	// Type is "counter", "gauge" or "histogram". Mandatory!
+	Type string
+
+	// Help of the metric.
+	Help string
+
+	// Labels are label keys that event is allowed to use.
+	Labels []string
+
+	// Buckets for histogram.
+	Buckets []float64
+}
+
+// Opts is an option for creating Handler.
+type Opts struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	// Metrics is the allowlist by metric name. Event for metric that is not listed is rejected.
+	Metrics map[string]Metric
+
+	// Expire and MaxLength are applied to every created vector, see dynamicvector.Opts.
+	Expire    time.Duration
+	MaxLength int
+
+	// Rate is maximum events per second for each client, with Burst as bucket size. Zero Rate means
+	// no limit. Zero Burst means the same as Rate.
+	Rate  float64
+	Burst int
+
+	// ClientKey identify client of request for rate limiting. Default is remote IP address.
+	ClientKey func(*http.Request) string
+
+	// MaxBodySize is maximum request body size in bytes. Default is 1MB.
+	MaxBodySize int64
+}
+
+// Result is the response body of Handler.
+type Result struct {
+	Accepted int          `json:"accepted"`
+	Errors   []EventError `json:"errors,omitempty"`
+}
+
+// EventError is error for a single event.
+type EventError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// Handler is http.Handler that accept POST of a single JSON event or array of events. It respond
+// with Result, status 200 when at least one event is accepted or there is no event, 400 when body
+// is invalid or every event is rejected, and 429 when every event is rate limited.
+type Handler struct {
+	opts    Opts
+	family  *dynamicvector.Family
+	limiter *ratelimit.Clients
+	allowed map[string]map[string]bool
+}
+
+// NewHandler will create new Handler that register created vectors to reg.
+func NewHandler(opts Opts, reg prometheus.Registerer) (*Handler, error) {
+	allowed := make(map[string]map[string]bool)
+	for name, m := range opts.Metrics {
+		switch m.Type {
+		case "counter", "gauge", "histogram":
+		default:
+			return nil, fmt.Errorf("jsonevent: metric %s has invalid type %q", name, m.Type)
+		}
+
+		allowed[name] = make(map[string]bool)
+		for _, key := range m.Labels {
+			allowed[name][key] = true
+		}
+	}
+
+	if opts.ClientKey == nil {
+		opts.ClientKey = remoteIP
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+
+	h := &Handler{
+		opts:    opts,
+		allowed: allowed,
+		family: dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+			Default: dynamicvector.Opts{
+				Namespace: opts.Namespace,
+				Expire:    opts.Expire,
+				MaxLength: opts.MaxLength,
+				Unchecked: true,
+			},
+			Customize: func(typ string, o *dynamicvector.Opts) {
+				m := opts.Metrics[o.Name]
+				o.Help, o.Buckets = m.Help, m.Buckets
+				if o.Help == "" {
+					o.Help = fmt.Sprintf("JSON event %s %s", typ, o.Name)
+				}
+			},
+		}, reg),
+	}
+	if opts.Rate > 0 {
+		h.limiter = ratelimit.NewClients(opts.Rate, opts.Burst)
+	}
+
+	return h, nil
+}
+
+// ServeHTTP implement http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, h.opts.MaxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > h.opts.MaxBodySize {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	events, err := decode(body)
+	if err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	allowed := len(events)
+	if h.limiter != nil {
+		allowed = h.limiter.TakeN(h.opts.ClientKey(r), len(events))
+	}
+
+	var res Result
+	for i, e := range events {
+		if i >= allowed {
+			res.Errors = append(res.Errors, EventError{Index: i, Error: "rate limit exceeded"})
+		} else if err := h.Apply(e); err != nil {
+			res.Errors = append(res.Errors, EventError{Index: i, Error: err.Error()})
+		} else {
+			res.Accepted++
+		}
+	}
+
+	code := http.StatusOK
+	if allowed == 0 && len(events) > 0 {
+		code = http.StatusTooManyRequests
+	} else if res.Accepted == 0 && len(events) > 0 {
+		code = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+// Apply validate and apply a single event.
+func (h *Handler) Apply(e Event) error {
+	m, ok := h.opts.Metrics[e.Metric]
+	if !ok {
+		return fmt.Errorf("metric %q is not allowed", e.Metric)
+	}
+	if e.Type != "" && e.Type != m.Type {
+		return fmt.Errorf("metric %q is %s, not %s", e.Metric, m.Type, e.Type)
+	}
+	for key := range e.Labels {
+		if !h.allowed[e.Metric][key] {
+			return fmt.Errorf("label %q is not allowed for metric %q", key, e.Metric)
+		}
+	}
+	if e.Value == nil && m.Type != "counter" {
+		return fmt.Errorf("metric %q needs value", e.Metric)
+	}
+
+	switch m.Type {
+	case "counter":
+		v := float64(1)
+		if e.Value != nil {
+			v = *e.Value
+		}
+		if v < 0 {
+			return fmt.Errorf("counter %q can not decrease", e.Metric)
+		}
+
+		c, err := h.family.Counter(e.Metric, e.Labels)
+		if err != nil {
+			return err
+		}
+		c.Add(v)
+	case "gauge":
+		g, err := h.family.Gauge(e.Metric, e.Labels)
+		if err != nil {
+			return err
+		}
+		g.Set(*e.Value)
+	case "histogram":
+		o, err := h.family.Histogram(e.Metric, e.Labels)
+		if err != nil {
+			return err
+		}
+		o.Observe(*e.Value)
+	}
+
+	return nil
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (h *Handler) Vectors() []*dynamicvector.Vector {
+	return h.family.Vectors()
+}
+
+// GC run GC on every vector and forget rate limit state of idle clients.
+func (h *Handler) GC() dynamicvector.GCStat {
+	if h.limiter != nil {
+		h.limiter.Cleanup()
+	}
+
+	return h.family.GC()
+}
+
+// decode decode body that contain a single event or array of events.
+func decode(body []byte) ([]Event, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var events []Event
+		err := json.Unmarshal(body, &events)
+		return events, err
+	}
+
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+	return []Event{e}, nil
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package jsonevent_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/jsonevent"
+	"github.com/stretchr/testify/assert"
+)
+
+var metrics = map[string]jsonevent.Metric{
+	"clicks_total":    {Type: "counter", Labels: []string{"page", "button"}},
+	"queue_size":      {Type: "gauge", Labels: []string{"queue"}},
+	"render_duration": {Type: "histogram", Labels: []string{"page"}, Buckets: []float64{0.1, 1}},
+}
+
+func TestHandler(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	h, err := jsonevent.NewHandler(jsonevent.Opts{Namespace: "web", Metrics: metrics}, reg)
+	assert.NoError(t, err)
+
+	code, res := post(h, `[
+		{"metric":"clicks_total","type":"counter","labels":{"page":"/","button":"buy"}},
+		{"metric":"clicks_total","labels":{"page":"/"},"value":2},
+		{"metric":"queue_size","type":"gauge","labels":{"queue":"q1"},"value":5},
+		{"metric":"render_duration","labels":{"page":"/"},"value":0.5},
+		{"metric":"unknown","value":1},
+		{"metric":"queue_size","type":"counter","value":1},
+		{"metric":"queue_size","labels":{"user":"1"},"value":1},
+		{"metric":"queue_size"},
+		{"metric":"clicks_total","value":-1}
+	]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 4, res.Accepted)
+	assert.Equal(t, 5, len(res.Errors))
+	assert.Equal(t, 4, res.Errors[0].Index)
+
+	mfs := gather(t, reg)
+	assert.Equal(t, 2, len(mfs["web_clicks_total"].Metric))
+	assert.Equal(t, float64(5), mfs["web_queue_size"].Metric[0].Gauge.GetValue())
+	assert.Equal(t, uint64(1), mfs["web_render_duration"].Metric[0].Histogram.GetSampleCount())
+	assert.Equal(t, 3, len(h.Vectors()))
+
+	code, res = post(h, `{"metric":"clicks_total","labels":{"page":"/"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, res.Accepted)
+
+	code, _ = post(h, `{"metric":"unknown"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = post(h, `{"metric":`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestHandler_RateLimit(t *testing.T) {
+	h, err := jsonevent.NewHandler(jsonevent.Opts{Metrics: metrics, Rate: 0.001, Burst: 2}, nil)
+	assert.NoError(t, err)
+
+	code, res := post(h, `[{"metric":"clicks_total"},{"metric":"clicks_total"},{"metric":"clicks_total"}]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, res.Accepted)
+	assert.Equal(t, []jsonevent.EventError{{Index: 2, Error: "rate limit exceeded"}}, res.Errors)
+
+	code, _ = post(h, `{"metric":"clicks_total"}`)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+
+	// other client has its own limit.
+	req := httptest.NewRequest("POST", "/events", strings.NewReader(`{"metric":"clicks_total"}`))
+	req.RemoteAddr = "10.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestNewHandler_Error(t *testing.T) {
+	_, err := jsonevent.NewHandler(jsonevent.Opts{Metrics: map[string]jsonevent.Metric{"x": {Type: "summary"}}}, nil)
+	assert.Error(t, err)
+}
+
+func post(h http.Handler, body string) (int, jsonevent.Result) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/events", strings.NewReader(body)))
+
+	var res jsonevent.Result
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec.Code, res
+}
+
+func gather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	mfs, err := g.Gather()
+	assert.NoError(t, err)
+
+	res := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf
+	}
+
+	return res
+}
@@ -33,22 +33,41 @@ func NewLabels(constantLabels prometheus.Labels) *Labels {
 	}
 }
 
+// addKey register label key if it is not registered yet.
+func (l *Labels) addKey(key string) {
+	if _, ok := l.index[key]; !ok {
+		l.index[key] = len(l.Keys)
+		l.Keys = append(l.Keys, key)
+	}
+}
+
 // PromLabelsToValues will generate label values from prometheus labels. If there is label key that
 // has not registered to Labels yet, it will be added.
 func (l *Labels) PromLabelsToValues(lbl prometheus.Labels) []string {
+	values, newKeys := l.values(lbl)
+	for _, key := range newKeys {
+		l.addKey(key)
+	}
+
+	return values
+}
+
+// values generate label values like PromLabelsToValues without registering new label keys. Values
+// of new keys are appended in the order of returned new keys.
+func (l *Labels) values(lbl prometheus.Labels) ([]string, []string) {
 	values := make([]string, len(l.Keys))
 
+	var newKeys []string
 	for key, value := range lbl {
 		if i, ok := l.index[key]; ok {
 			values[i] = value
 		} else {
-			l.index[key] = len(l.Keys)
-			l.Keys = append(l.Keys, key)
+			newKeys = append(newKeys, key)
 			values = append(values, value)
 		}
 	}
 
-	return values
+	return values, newKeys
 }
 
 // ValuesToPromLabels will generate prometheus.Labels given label values and constant labels.
@@ -82,6 +101,21 @@ func (l *Labels) Hash(label prometheus.Labels) uint64 {
 	return farmhash.Hash64(bytes.TrimRight(b.Bytes(), "\x00"))
 }
 
+// HashValues will return hash value from label values ordered by Keys. It is equal to Hash of
+// labels with the same values.
+func (l *Labels) HashValues(values []string) uint64 {
+	var buf [256]byte
+	b := buf[:0]
+	for i := range l.Keys {
+		if i < len(values) {
+			b = append(b, values[i]...)
+		}
+		b = append(b, 0)
+	}
+
+	return farmhash.Hash64(bytes.TrimRight(b, "\x00"))
+}
+
 // Include will check whether lbl is subset of Labels or not.
 func (l *Labels) Include(lbl prometheus.Labels) bool {
 	if len(lbl) > len(l.index) {
@@ -59,6 +59,17 @@ func TestLabels_Hash(t *testing.T) {
 	assert.NotEqual(t, l.Hash(lbl1), l.Hash(lbl3))
 }
 
+func TestLabels_HashValues(t *testing.T) {
+	l := createLabels()
+	l.PromLabelsToValues(prometheus.Labels{"key1": ""})
+	l.PromLabelsToValues(prometheus.Labels{"key2": ""})
+
+	assert.Equal(t, l.Hash(prometheus.Labels{"key1": "a"}), l.HashValues([]string{"a"}))
+	assert.Equal(t, l.Hash(prometheus.Labels{"key1": "a"}), l.HashValues([]string{"a", ""}))
+	assert.Equal(t, l.Hash(prometheus.Labels{"key1": "a", "key2": "b"}), l.HashValues([]string{"a", "b"}))
+	assert.Equal(t, l.Hash(prometheus.Labels{"key2": "b"}), l.HashValues([]string{"", "b"}))
+}
+
 func TestLabels_Include(t *testing.T) {
 	l := createLabels()
 	l.PromLabelsToValues(prometheus.Labels{"key1": "", "key3": ""})
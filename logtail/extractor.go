@@ -0,0 +1,215 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package logtail extract metrics from log files. Lines are matched against regular expressions,
+// and named capture groups become labels of dynamic vectors.
+package logtail
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Rule turn matching line into metric update.
+type Rule struct {
+	// Name of the metric. Mandatory!
+	Name string
+
+	// Help of the metric.
+	Help string
+
+	// Type is "counter", "gauge" or "histogram". Mandatory!
+	Type string
+
+	// Pattern is matched against every line. Named capture groups become labels, except Value.
+	Pattern *regexp.Regexp
+
+	// Value is the named capture group that hold the value. Counter without Value is incremented
+	// by one, gauge and histogram need it.
+	Value string
+
+	// Buckets for histogram. Default is prometheus.DefBuckets.
+	Buckets []float64
+}
+
+// Opts is an option for creating Extractor.
+type Opts struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	// Rules are applied to every line, every matching rule update its metric.
+	Rules []Rule
+
+	// Expire and MaxLength are applied to every vector, see dynamicvector.Opts.
+	Expire    time.Duration
+	MaxLength int
+
+	// ErrorHandler is called when matching line can not be applied. Nil means error is ignored.
+	ErrorHandler func(error)
+}
+
+// Extractor apply rules to log lines.
+type Extractor struct {
+	opts    Opts
+	rules   []rule
+	vectors []*dynamicvector.Vector
+}
+
+type rule struct {
+	Rule
+	counter   *dynamicvector.Counter
+	gauge     *dynamicvector.Gauge
+	histogram *dynamicvector.Histogram
+}
+
+// NewExtractor will create new Extractor and register its vectors to reg. Rules with the same
+// name share a vector, so they must have the same type, Help and Buckets.
+func NewExtractor(opts Opts, reg prometheus.Registerer) (*Extractor, error) {
+	e := &Extractor{opts: opts}
+
+	byName := make(map[string]rule)
+	for _, r := range opts.Rules {
+		if r.Pattern == nil {
+			return nil, fmt.Errorf("logtail: rule %s has no pattern", r.Name)
+		}
+		if r.Value != "" && r.Pattern.SubexpIndex(r.Value) < 0 {
+			return nil, fmt.Errorf("logtail: rule %s pattern has no capture group %s", r.Name, r.Value)
+		}
+		if r.Value == "" && r.Type != "counter" {
+			return nil, fmt.Errorf("logtail: rule %s needs Value", r.Name)
+		}
+
+		if existing, ok := byName[r.Name]; ok {
+			if existing.Type != r.Type {
+				return nil, fmt.Errorf("logtail: rule %s is %s and %s", r.Name, existing.Type, r.Type)
+			}
+			if existing.Help != r.Help || !reflect.DeepEqual(existing.Buckets, r.Buckets) {
+				return nil, fmt.Errorf("logtail: rules %s have different Help or Buckets", r.Name)
+			}
+			e.rules = append(e.rules, rule{Rule: r, counter: existing.counter, gauge: existing.gauge, histogram: existing.histogram})
+			continue
+		}
+
+		vopts := dynamicvector.Opts{
+			Namespace: opts.Namespace,
+			Name:      r.Name,
+			Help:      r.Help,
+			Buckets:   r.Buckets,
+			Expire:    opts.Expire,
+			MaxLength: opts.MaxLength,
+			Unchecked: true,
+		}
+		if vopts.Help == "" {
+			vopts.Help = fmt.Sprintf("Log %s %s", r.Type, r.Name)
+		}
+
+		nr := rule{Rule: r}
+		var c prometheus.Collector
+		switch r.Type {
+		case "counter":
+			nr.counter = dynamicvector.NewCounter(vopts)
+			c = nr.counter
+			e.vectors = append(e.vectors, nr.counter.Vector)
+		case "gauge":
+			nr.gauge = dynamicvector.NewGauge(vopts)
+			c = nr.gauge
+			e.vectors = append(e.vectors, nr.gauge.Vector)
+		case "histogram":
+			if vopts.Buckets == nil {
+				vopts.Buckets = prometheus.DefBuckets
+			}
+			nr.histogram = dynamicvector.NewHistogram(vopts)
+			c = nr.histogram
+			e.vectors = append(e.vectors, nr.histogram.Vector)
+		default:
+			return nil, fmt.Errorf("logtail: rule %s has invalid type %q", r.Name, r.Type)
+		}
+
+		if reg != nil {
+			if err := reg.Register(c); err != nil {
+				return nil, err
+			}
+		}
+		e.rules = append(e.rules, nr)
+		byName[r.Name] = nr
+	}
+
+	return e, nil
+}
+
+// Process apply all rules to a single line.
+func (e *Extractor) Process(line string) {
+	for i := range e.rules {
+		if err := e.rules[i].apply(line); err != nil && e.opts.ErrorHandler != nil {
+			e.opts.ErrorHandler(err)
+		}
+	}
+}
+
+// Tail follow file with t and process every new line until ctx is done.
+func (e *Extractor) Tail(ctx context.Context, t *Tailer) error {
+	return t.Run(ctx, e.Process)
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (e *Extractor) Vectors() []*dynamicvector.Vector {
+	return e.vectors
+}
+
+func (r *rule) apply(line string) error {
+	match := r.Pattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	lbl := make(prometheus.Labels)
+	value := float64(1)
+	for i, name := range r.Pattern.SubexpNames() {
+		switch {
+		case name == "" || i >= len(match):
+		case name == r.Value:
+			v, err := strconv.ParseFloat(match[i], 64)
+			if err != nil {
+				return fmt.Errorf("logtail: rule %s: invalid value %q", r.Name, match[i])
+			}
+			value = v
+		default:
+			lbl[name] = match[i]
+		}
+	}
+
+	switch {
+	case r.counter != nil:
+		if value < 0 {
+			return fmt.Errorf("logtail: rule %s: counter can not decrease", r.Name)
+		}
+		c, err := r.counter.GetMetricWith(lbl)
+		if err != nil {
+			return err
+		}
+		c.Add(value)
+	case r.gauge != nil:
+		g, err := r.gauge.GetMetricWith(lbl)
+		if err != nil {
+			return err
+		}
+		g.Set(value)
+	case r.histogram != nil:
+		h, err := r.histogram.GetMetricWith(lbl)
+		if err != nil {
+			return err
+		}
+		h.Observe(value)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package logtail_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/logtail"
+	"github.com/stretchr/testify/assert"
+)
+
+var accessLog = regexp.MustCompile(`^(?P<method>[A-Z]+) (?P<path>\S+) (?P<code>\d+) (?P<duration>[\d.]+)s$`)
+
+func TestExtractor_Process(t *testing.T) {
+	var errs []error
+	reg := prometheus.NewPedanticRegistry()
+	e, err := logtail.NewExtractor(logtail.Opts{
+		Namespace: "app",
+		Rules: []logtail.Rule{
+			{Name: "requests_total", Type: "counter", Pattern: regexp.MustCompile(`^(?P<method>[A-Z]+) (?P<path>\S+) (?P<code>\d+) `)},
+			{Name: "request_duration_seconds", Type: "histogram", Pattern: accessLog, Value: "duration", Buckets: []float64{0.1, 1}},
+			{Name: "queue_length", Type: "gauge", Pattern: regexp.MustCompile(`queue (?P<queue>\w+) length (?P<length>-?\d+)`), Value: "length"},
+			{Name: "errors_total", Type: "counter", Pattern: regexp.MustCompile(`ERROR (?P<component>\w+)`)},
+			{Name: "errors_total", Type: "counter", Pattern: regexp.MustCompile(`PANIC`)},
+		},
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	}, reg)
+	assert.NoError(t, err)
+
+	for _, line := range []string{
+		"GET / 200 0.05s",
+		"GET / 200 0.5s",
+		"POST /login 500 2s",
+		"queue emails length 10",
+		"queue emails length 7",
+		"ERROR db connection refused",
+		"PANIC",
+		"unrelated line",
+	} {
+		e.Process(line)
+	}
+
+	mfs := gather(t, reg)
+	assert.Equal(t, 2, len(mfs["app_requests_total"].Metric))
+	assert.Equal(t, 2, len(mfs["app_request_duration_seconds"].Metric))
+	assert.Equal(t, float64(7), mfs["app_queue_length"].Metric[0].Gauge.GetValue())
+	assert.Equal(t, 2, len(mfs["app_errors_total"].Metric))
+	assert.Equal(t, 4, len(e.Vectors()))
+	assert.Empty(t, errs)
+}
+
+func TestNewExtractor_Error(t *testing.T) {
+	re := regexp.MustCompile(`(?P<value>\d+)`)
+	for _, rules := range [][]logtail.Rule{
+		{{Name: "a", Type: "counter"}},
+		{{Name: "a", Type: "summary", Pattern: re, Value: "value"}},
+		{{Name: "a", Type: "gauge", Pattern: re}},
+		{{Name: "a", Type: "gauge", Pattern: re, Value: "other"}},
+		{{Name: "a", Type: "gauge", Pattern: re, Value: "value"}, {Name: "a", Type: "counter", Pattern: re}},
+		{{Name: "a", Type: "counter", Pattern: re, Help: "A."}, {Name: "a", Type: "counter", Pattern: re, Help: "B."}},
+		{{Name: "a", Type: "histogram", Pattern: re, Value: "value"}, {Name: "a", Type: "histogram", Pattern: re, Value: "value", Buckets: []float64{1}}},
+	} {
+		_, err := logtail.NewExtractor(logtail.Opts{Rules: rules}, nil)
+		assert.Error(t, err)
+	}
+}
+
+func gather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	mfs, err := g.Gather()
+	assert.NoError(t, err)
+
+	res := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf
+	}
+
+	return res
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package logtail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Tailer follow a file like tail -F. It reopen the file when it is rotated and read from the
+// beginning when it is truncated. Rotated file is read until its end before it is closed, and its
+// last line is passed even without newline.
+type Tailer struct {
+	// Path of the file.
+	Path string
+
+	// Poll is interval to check new data, rotation and truncation. Default is 250ms.
+	Poll time.Duration
+
+	// FromStart read existing content of the file. By default only new lines are read.
+	FromStart bool
+}
+
+// Run read lines and call fn for every complete line until ctx is done. File that does not exist
+// yet is waited.
+func (t *Tailer) Run(ctx context.Context, fn func(line string)) error {
+	poll := t.Poll
+	if poll == 0 {
+		poll = 250 * time.Millisecond
+	}
+
+	var (
+		f       *os.File
+		info    os.FileInfo
+		r       *bufio.Reader
+		offset  int64
+		partial string
+		first   = true
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	// readLines read f until EOF and call fn for every complete line.
+	readLines := func() {
+		for {
+			s, err := r.ReadString('\n')
+			offset += int64(len(s))
+			if err != nil {
+				partial += s
+				return
+			}
+			fn(strings.TrimRight(partial+s, "\r\n"))
+			partial = ""
+		}
+	}
+
+	for {
+		if f == nil {
+			var err error
+			if f, info, err = open(t.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if f != nil {
+				offset = 0
+				if first && !t.FromStart {
+					if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+						return err
+					}
+				}
+				r = bufio.NewReader(f)
+				partial = ""
+			}
+			first = false
+		}
+
+		if f != nil {
+			readLines()
+
+			cur, err := os.Stat(t.Path)
+			switch {
+			case err != nil && !os.IsNotExist(err):
+				return err
+			case err != nil || !os.SameFile(info, cur):
+				// rotated, lines written before rotation may come after the read above.
+				readLines()
+				if partial != "" {
+					fn(strings.TrimRight(partial, "\r\n"))
+				}
+				f.Close()
+				f = nil
+				continue
+			case cur.Size() < offset:
+				// truncated.
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				r.Reset(f)
+				offset = 0
+				partial = ""
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+		}
+	}
+}
+
+func open(path string) (*os.File, os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, info, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package logtail_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rolandhawk/dynamicvector/logtail"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailer_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtail")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("old\n"), 0644))
+
+	lines := make(chan string, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		tailer := &logtail.Tailer{Path: path, Poll: 10 * time.Millisecond}
+		done <- tailer.Run(ctx, func(line string) { lines <- line })
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// append, with partial line.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	f.WriteString("line1\nli")
+	time.Sleep(50 * time.Millisecond)
+	f.WriteString("ne2\n")
+	f.Close()
+	assert.Equal(t, []string{"line1", "line2"}, read(t, lines, 2))
+
+	// truncate.
+	assert.NoError(t, ioutil.WriteFile(path, []byte("a\n"), 0644))
+	assert.Equal(t, []string{"a"}, read(t, lines, 1))
+
+	// rotate, old file is read until its end.
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Rename(path, path+".1"))
+	f.WriteString("b\nlast")
+	f.Close()
+	assert.NoError(t, ioutil.WriteFile(path, []byte("rotated\n"), 0644))
+	assert.Equal(t, []string{"b", "last", "rotated"}, read(t, lines, 3))
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func read(t *testing.T, ch chan string, n int) []string {
+	var res []string
+	for len(res) < n {
+		select {
+		case line := <-ch:
+			res = append(res, line)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout, got %v", res)
+		}
+	}
+
+	return res
+}
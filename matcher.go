@@ -0,0 +1,128 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MatchType is type of label matcher.
+type MatchType int
+
+// Possible MatchType.
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+func (t MatchType) String() string {
+	switch t {
+	case MatchEqual:
+		return "="
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	}
+	return ""
+}
+
+// Matcher match a single label value. Label that does not exist is treated as empty value,
+// the same way prometheus does.
+type Matcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// NewMatcher will create new Matcher. Regexp value is anchored on both end.
+func NewMatcher(t MatchType, name, value string) (*Matcher, error) {
+	m := &Matcher{Type: t, Name: name, Value: value}
+	if t == MatchRegexp || t == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, err
+		}
+		m.re = re
+	}
+
+	return m, nil
+}
+
+// ParseMatcher will parse matcher in form of name=value, name!=value, name=~regexp or name!~regexp.
+// Value can be double quoted.
+func ParseMatcher(s string) (*Matcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid matcher %q", s)
+	}
+
+	var t MatchType
+	name, op := strings.TrimSpace(s[:i]), s[i:]
+	switch {
+	case strings.HasPrefix(op, "=~"):
+		t, op = MatchRegexp, op[2:]
+	case strings.HasPrefix(op, "!~"):
+		t, op = MatchNotRegexp, op[2:]
+	case strings.HasPrefix(op, "!="):
+		t, op = MatchNotEqual, op[2:]
+	case strings.HasPrefix(op, "="):
+		t, op = MatchEqual, op[1:]
+	default:
+		return nil, fmt.Errorf("invalid matcher %q", s)
+	}
+
+	value := strings.TrimSpace(op)
+	if strings.HasPrefix(value, `"`) {
+		var err error
+		if value, err = strconv.Unquote(value); err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %s", s, err)
+		}
+	}
+
+	return NewMatcher(t, name, value)
+}
+
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// Matches will check whether value is matched.
+func (m *Matcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// MatchLabels will check whether all matchers are matched by labels.
+func MatchLabels(lbl prometheus.Labels, matchers ...*Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbl[m.Name]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMatcher(t *testing.T) {
+	cases := []struct {
+		in    string
+		t     dynamicvector.MatchType
+		name  string
+		value string
+	}{
+		{`key=value`, dynamicvector.MatchEqual, "key", "value"},
+		{`key="value"`, dynamicvector.MatchEqual, "key", "value"},
+		{`key=`, dynamicvector.MatchEqual, "key", ""},
+		{`key!=value`, dynamicvector.MatchNotEqual, "key", "value"},
+		{`key=~"val.*"`, dynamicvector.MatchRegexp, "key", "val.*"},
+		{`key!~val.*`, dynamicvector.MatchNotRegexp, "key", "val.*"},
+	}
+
+	for _, c := range cases {
+		m, err := dynamicvector.ParseMatcher(c.in)
+		assert.NoError(t, err, c.in)
+		assert.Equal(t, c.t, m.Type, c.in)
+		assert.Equal(t, c.name, m.Name, c.in)
+		assert.Equal(t, c.value, m.Value, c.in)
+	}
+
+	for _, in := range []string{"", "key", "=value", "key!value", `key="value`, "key=~("} {
+		_, err := dynamicvector.ParseMatcher(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestMatcher_Matches(t *testing.T) {
+	eq, _ := dynamicvector.NewMatcher(dynamicvector.MatchEqual, "key", "value")
+	neq, _ := dynamicvector.NewMatcher(dynamicvector.MatchNotEqual, "key", "value")
+	re, _ := dynamicvector.NewMatcher(dynamicvector.MatchRegexp, "key", "val")
+	nre, _ := dynamicvector.NewMatcher(dynamicvector.MatchNotRegexp, "key", "val.*")
+
+	assert.True(t, eq.Matches("value"))
+	assert.False(t, eq.Matches("other"))
+	assert.True(t, neq.Matches("other"))
+	assert.False(t, neq.Matches("value"))
+	assert.True(t, re.Matches("val"))
+	assert.False(t, re.Matches("value"))
+	assert.True(t, nre.Matches("other"))
+	assert.False(t, nre.Matches("value"))
+}
+
+func TestMatchLabels(t *testing.T) {
+	eq, _ := dynamicvector.NewMatcher(dynamicvector.MatchEqual, "key1", "value")
+	empty, _ := dynamicvector.NewMatcher(dynamicvector.MatchEqual, "key2", "")
+
+	assert.True(t, dynamicvector.MatchLabels(prometheus.Labels{"key1": "value"}, eq, empty))
+	assert.False(t, dynamicvector.MatchLabels(prometheus.Labels{"key1": "value", "key2": "value"}, eq, empty))
+	assert.True(t, dynamicvector.MatchLabels(prometheus.Labels{}))
+}
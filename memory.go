@@ -0,0 +1,81 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"unsafe"
+)
+
+// These sizes are rough estimation of Go runtime memory layout.
+const (
+	stringHeaderSize = int(unsafe.Sizeof(""))
+	sliceHeaderSize  = int(unsafe.Sizeof([]string(nil)))
+
+	// seriesOverhead is size of an entry in vector metrics map.
+	seriesOverhead = 8 + int(unsafe.Sizeof(Metric(nil))) + 8
+
+	// bucketSize is size of an entry in histogram buckets map.
+	bucketSize = 8 + 8 + 8
+
+	// defaultMetricSize is used for metric that does not implement MemorySizer.
+	defaultMetricSize = 256
+)
+
+// MemorySizer is implemented by Metric that can estimate its own memory usage. Metric constructed
+// by custom constructor may implement it, otherwise defaultMetricSize is used. The returned size
+// must not change during metric lifetime.
+type MemorySizer interface {
+	// MemorySize return estimated number of bytes used by the metric, including its label values.
+	MemorySize() int
+}
+
+// MemoryUsage return estimated number of bytes used by metrics in this vector.
+func (v *Vector) MemoryUsage() int {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	return v.memoryUsage()
+}
+
+func (v *Vector) memoryUsage() int {
+	n := v.bytes
+	for _, key := range v.labels.Keys {
+		n += stringHeaderSize + len(key)
+	}
+	return n
+}
+
+// LabelValuesSize return estimated number of bytes used by label values slice. It is useful for
+// implementing MemorySizer.
+func LabelValuesSize(values []string) int {
+	n := sliceHeaderSize
+	for _, value := range values {
+		n += stringHeaderSize + len(value)
+	}
+	return n
+}
+
+func metricSize(m Metric) int {
+	if s, ok := m.(MemorySizer); ok {
+		return seriesOverhead + s.MemorySize()
+	}
+	return seriesOverhead + defaultMetricSize
+}
+
+// exceedMaxBytes return true when adding metric, with its new label keys, would use more than
+// Opts.MaxBytes.
+func (v *Vector) exceedMaxBytes(m Metric, newKeys []string) bool {
+	max := v.meta().opts.MaxBytes
+	if max <= 0 {
+		return false
+	}
+
+	n := v.memoryUsage() + metricSize(m)
+	for _, key := range newKeys {
+		n += stringHeaderSize + len(key)
+	}
+	return n > max
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_MemoryUsage(t *testing.T) {
+	g := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "g", Help: "help"})
+	h := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "h", Help: "help", Buckets: prometheus.DefBuckets})
+	assert.Equal(t, 0, g.MemoryUsage())
+
+	g.With(prometheus.Labels{"key": "a"}).Set(1)
+	h.With(prometheus.Labels{"key": "a"}).Observe(1)
+	short := g.MemoryUsage()
+	assert.True(t, short > 0)
+	assert.True(t, h.MemoryUsage() > short)
+
+	g.With(prometheus.Labels{"key": strings.Repeat("a", 100)}).Set(1)
+	assert.True(t, g.MemoryUsage()-short > short)
+
+	g.Delete(prometheus.Labels{"key": "a"})
+	g.Delete(prometheus.Labels{"key": strings.Repeat("a", 100)})
+	empty := g.MemoryUsage()
+	assert.True(t, empty > 0 && empty < short)
+
+	g.With(prometheus.Labels{"key": "a"}).Set(1)
+	assert.Equal(t, short, g.MemoryUsage())
+	g.Reset()
+	assert.Equal(t, 0, g.MemoryUsage())
+}
+
+func TestVector_MaxBytes(t *testing.T) {
+	g := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "g", Help: "help"})
+	g.With(prometheus.Labels{"key": "a"}).Set(1)
+	g.With(prometheus.Labels{"key": "b"}).Set(1)
+	size := g.MemoryUsage()
+
+	// limit is never exceeded, not even by one metric.
+	g = dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "g", Help: "help", MaxBytes: size})
+	_, err := g.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	_, err = g.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.NoError(t, err)
+	_, err = g.GetMetricWith(prometheus.Labels{"key": "c"})
+	assert.Error(t, err)
+	assert.Equal(t, size, g.MemoryUsage())
+
+	// existing metric is still accessible.
+	_, err = g.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+
+	g.Delete(prometheus.Labels{"key": "a"})
+	_, err = g.GetMetricWith(prometheus.Labels{"key": "c"})
+	assert.NoError(t, err)
+}
@@ -26,6 +26,10 @@ type Opts struct {
 	// ConstLabels are used to attach fixed labels to this metric.
 	ConstLabels prometheus.Labels
 
+	// LabelNames declare order of label keys for WithLabelValues. Other label keys can still be
+	// added with With.
+	LabelNames []string
+
 	// Buckets defines the buckets into which observations are counted. Only for Histogram.
 	Buckets []float64
 
@@ -35,6 +39,43 @@ type Opts struct {
 
 	// MaxLength is maximum length that this vector is allowed to have. Zero mean no maximum length.
 	MaxLength int
+
+	// MaxBytes is maximum estimated memory in bytes that metrics in this vector are allowed to use,
+	// see Vector.MemoryUsage. New metric is rejected once it is reached. Zero mean no limit.
+	MaxBytes int
+
+	// CreateRate is maximum number of new metrics per second. CreateBurst is maximum number of new
+	// metrics created at once, default is CreateRate. Zero CreateRate mean no limit.
+	CreateRate  float64
+	CreateBurst int
+
+	// KeyCreateRate is maximum number of new metrics per second that have given label key set.
+	// It use CreateBurst as its burst.
+	KeyCreateRate map[string]float64
+
+	// OverflowValue route metric creation that exceed CreateRate or KeyCreateRate to overflow
+	// metric, which has every label value replaced with OverflowValue. Empty mean the creation is
+	// rejected with RateLimitError.
+	OverflowValue string
+
+	// CallerSampleRate record call site of 1 in every CallerSampleRate new metrics, see
+	// Vector.CreationSites. Zero mean no call site is recorded.
+	CallerSampleRate int
+
+	// Unchecked makes the vector behave as an unchecked collector. Describe will send nothing and
+	// every collected metric has its own Desc that only contains label keys which are set for that
+	// metric. Use it when registering the vector to prometheus.Registry, including pedantic one.
+	Unchecked bool
+
+	// Budget is a limit of number of metrics shared with other vectors. Nil mean no shared limit.
+	Budget *Budget
+
+	// BudgetReserve is number of metrics in Budget that is reserved for this vector.
+	BudgetReserve int
+
+	// BudgetWeight is importance of this vector metrics when Budget evict. Metric of vector with
+	// higher weight is kept longer. Zero mean 1.
+	BudgetWeight float64
 }
 
 // HistogramOpts is an alias for Opts
@@ -45,3 +86,23 @@ type CounterOpts = Opts
 
 // GaugeOpts is an alias for Opts
 type GaugeOpts = Opts
+
+// copyOpts return copy of opts that does not share maps and slices with it. Budget is shared.
+func copyOpts(opts Opts) Opts {
+	opts.ConstLabels = copyLabels(opts.ConstLabels)
+	if opts.LabelNames != nil {
+		opts.LabelNames = append([]string{}, opts.LabelNames...)
+	}
+	if opts.Buckets != nil {
+		opts.Buckets = append([]float64{}, opts.Buckets...)
+	}
+	if opts.KeyCreateRate != nil {
+		rates := make(map[string]float64, len(opts.KeyCreateRate))
+		for k, rate := range opts.KeyCreateRate {
+			rates[k] = rate
+		}
+		opts.KeyCreateRate = rates
+	}
+
+	return opts
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/internal/ratelimit"
+)
+
+// RateLimitError is returned when new metric is throttled by Opts.CreateRate or Opts.KeyCreateRate.
+type RateLimitError struct {
+	// Name is fully qualified name of the vector.
+	Name string
+
+	// Key is label key whose creation rate is exceeded. Empty mean vector creation rate.
+	Key string
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("vector %s exceed creation rate", e.Name)
+	}
+	return fmt.Sprintf("vector %s exceed creation rate of label %s", e.Name, e.Key)
+}
+
+// ThrottleStat is number of metric creations that are throttled.
+type ThrottleStat struct {
+	// Rejected is number of creations that return RateLimitError.
+	Rejected int
+
+	// Overflowed is number of creations that are routed to overflow metric.
+	Overflowed int
+
+	// Keys is number of throttled creations per label key. Empty key is for vector creation rate.
+	Keys map[string]int
+}
+
+// Throttled return number of metric creations that are throttled since vector is created.
+func (v *Vector) Throttled() ThrottleStat {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	stat := v.throttled
+	stat.Keys = make(map[string]int, len(v.throttled.Keys))
+	for k, n := range v.throttled.Keys {
+		stat.Keys[k] = n
+	}
+	return stat
+}
+
+// throttle check creation token for new metric with labels l without taking it, see takeToken.
+// Throttled creation is recorded. It must be called with write lock.
+func (v *Vector) throttle(l prometheus.Labels) error {
+	now := time.Now()
+
+	var key string
+	limited := v.createLimit != nil && !v.createLimit.Allow(now)
+	if !limited {
+		for k, value := range l {
+			if b, ok := v.keyLimits[k]; ok && value != "" && !b.Allow(now) {
+				key, limited = k, true
+				break
+			}
+		}
+	}
+
+	if limited {
+		v.throttled.Keys[key]++
+		if v.opts.OverflowValue == "" {
+			v.throttled.Rejected++
+		} else {
+			v.throttled.Overflowed++
+		}
+		return &RateLimitError{Name: v.Name(), Key: key}
+	}
+	return nil
+}
+
+// takeToken take creation token for new metric with labels l. It is called after every other
+// limit is checked, so failed creation does not take any token. It must be called with write lock.
+func (v *Vector) takeToken(l prometheus.Labels) {
+	if v.createLimit != nil {
+		v.createLimit.Take()
+	}
+	for k, value := range l {
+		if b, ok := v.keyLimits[k]; ok && value != "" {
+			b.Take()
+		}
+	}
+}
+
+// overflowLabels return labels of overflow metric for labels l.
+func (v *Vector) overflowLabels(l prometheus.Labels) prometheus.Labels {
+	overflow := make(prometheus.Labels, len(l))
+	for k, value := range l {
+		if value != "" {
+			overflow[k] = v.opts.OverflowValue
+		}
+	}
+	return overflow
+}
+
+func (v *Vector) initLimits() {
+	now := time.Now()
+	if v.opts.CreateRate > 0 {
+		v.createLimit = ratelimit.NewTokenBucket(v.opts.CreateRate, v.opts.CreateBurst, now)
+	}
+
+	v.keyLimits = make(map[string]*ratelimit.TokenBucket, len(v.opts.KeyCreateRate))
+	for k, rate := range v.opts.KeyCreateRate {
+		v.keyLimits[k] = ratelimit.NewTokenBucket(rate, v.opts.CreateBurst, now)
+	}
+	v.throttled.Keys = make(map[string]int)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_CreateRate(t *testing.T) {
+	c := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:        "c",
+		Help:        "help",
+		CreateRate:  100,
+		CreateBurst: 2,
+	})
+
+	_, err := c.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	_, err = c.GetMetricWith(prometheus.Labels{"key": "b"})
+	assert.NoError(t, err)
+
+	_, err = c.GetMetricWith(prometheus.Labels{"key": "c"})
+	if assert.IsType(t, &dynamicvector.RateLimitError{}, err) {
+		assert.Equal(t, "", err.(*dynamicvector.RateLimitError).Key)
+	}
+
+	// existing metric is not throttled.
+	_, err = c.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = c.GetMetricWith(prometheus.Labels{"key": "c"})
+	assert.NoError(t, err)
+
+	stat := c.Throttled()
+	assert.Equal(t, 1, stat.Rejected)
+	assert.Equal(t, 0, stat.Overflowed)
+	assert.Equal(t, map[string]int{"": 1}, stat.Keys)
+}
+
+func TestVector_CreateRate_FailedCreation(t *testing.T) {
+	c := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:        "c",
+		Help:        "help",
+		CreateRate:  0.001,
+		CreateBurst: 1,
+		MaxBytes:    1,
+	})
+
+	// creation that fail on another limit does not take creation token.
+	_, err := c.GetMetricWith(prometheus.Labels{"key": "a"})
+	_, throttled := err.(*dynamicvector.RateLimitError)
+	assert.Error(t, err)
+	assert.False(t, throttled)
+
+	assert.NoError(t, c.Update(func(opts *dynamicvector.Opts) { opts.MaxBytes = 0 }))
+	_, err = c.GetMetricWith(prometheus.Labels{"key": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.Throttled().Rejected)
+}
+
+func TestVector_KeyCreateRate(t *testing.T) {
+	c := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:          "c",
+		Help:          "help",
+		KeyCreateRate: map[string]float64{"user": 0.001},
+	})
+
+	_, err := c.GetMetricWith(prometheus.Labels{"user": "a"})
+	assert.NoError(t, err)
+	_, err = c.GetMetricWith(prometheus.Labels{"user": "b"})
+	if assert.IsType(t, &dynamicvector.RateLimitError{}, err) {
+		assert.Equal(t, "user", err.(*dynamicvector.RateLimitError).Key)
+	}
+
+	// metric without the limited key is not throttled.
+	for _, code := range []string{"200", "404", "500"} {
+		_, err = c.GetMetricWith(prometheus.Labels{"code": code})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, map[string]int{"user": 1}, c.Throttled().Keys)
+}
+
+func TestVector_OverflowValue(t *testing.T) {
+	c := dynamicvector.NewCounter(dynamicvector.CounterOpts{
+		Name:          "c",
+		Help:          "help",
+		CreateRate:    0.001,
+		CreateBurst:   1,
+		OverflowValue: "overflow",
+	})
+
+	c.With(prometheus.Labels{"key": "a"}).Inc()
+	c.With(prometheus.Labels{"key": "b"}).Inc()
+	c.With(prometheus.Labels{"key": "c"}).Inc()
+
+	series, err := c.Series()
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(series)) {
+		assert.Equal(t, prometheus.Labels{"key": "a"}, series[0].Labels)
+		assert.Equal(t, prometheus.Labels{"key": "overflow"}, series[1].Labels)
+		assert.Equal(t, 2.0, series[1].Metric.GetCounter().GetValue())
+	}
+
+	stat := c.Throttled()
+	assert.Equal(t, 0, stat.Rejected)
+	assert.Equal(t, 2, stat.Overflowed)
+}
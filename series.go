@@ -0,0 +1,132 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Series is a snapshot of a single metric in vector.
+type Series struct {
+	// Labels contain dynamic labels that have value and constant labels.
+	Labels prometheus.Labels
+
+	// Metric is the value written by the metric.
+	Metric *dto.Metric
+
+	// LastEdit is last time metric is edited.
+	LastEdit time.Time
+
+	// Created is the time when metric is created. Zero if the metric does not record it.
+	Created time.Time
+}
+
+// Name return fully-qualified name of this vector.
+func (v *Vector) Name() string {
+	return prometheus.BuildFQName(v.opts.Namespace, v.opts.Subsystem, v.opts.Name)
+}
+
+// Series return snapshot of all live metrics that match all matchers, sorted by its labels.
+// Like Collect, it return nothing when vector exceed MaxLength.
+func (v *Vector) Series(matchers ...*Matcher) ([]Series, error) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	if v.exceedMaxLength() {
+		return nil, nil
+	}
+
+	var series []Series
+	for _, m := range v.metrics {
+		if v.isExpire(m.LastEdit()) {
+			continue
+		}
+
+		s, err := newSeries(m)
+		if err != nil {
+			return nil, err
+		}
+		if MatchLabels(s.Labels, matchers...) {
+			series = append(series, s)
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return labelsString(series[i].Labels) < labelsString(series[j].Labels)
+	})
+
+	return series, nil
+}
+
+// DeleteMatching delete all metrics that match all matchers and return number of deleted metrics.
+// Every metric is deleted when there is no matcher.
+func (v *Vector) DeleteMatching(matchers ...*Matcher) (int, error) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	deleted := 0
+	for h, m := range v.metrics {
+		s, err := newSeries(m)
+		if err != nil {
+			return deleted, err
+		}
+		if MatchLabels(s.Labels, matchers...) {
+			v.remove(h, m)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func newSeries(m Metric) (Series, error) {
+	var metric dto.Metric
+	if err := m.Write(&metric); err != nil {
+		return Series{}, err
+	}
+
+	s := Series{
+		Labels:   make(prometheus.Labels),
+		Metric:   &metric,
+		LastEdit: m.LastEdit(),
+	}
+	for _, lp := range metric.Label {
+		if lp.GetValue() != "" {
+			s.Labels[lp.GetName()] = lp.GetValue()
+		}
+	}
+	if c, ok := m.(interface{ Created() time.Time }); ok {
+		s.Created = c.Created()
+	}
+
+	return s, nil
+}
+
+// labelsString return labels in form of {name="value",...} sorted by label name.
+func labelsString(lbl prometheus.Labels) string {
+	names := make([]string, 0, len(lbl))
+	for name := range lbl {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := []byte{'{'}
+	for i, name := range names {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, name...)
+		b = append(b, '=')
+		b = strconv.AppendQuote(b, lbl[name])
+	}
+
+	return string(append(b, '}'))
+}
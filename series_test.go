@@ -0,0 +1,68 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVector_Name(t *testing.T) {
+	v := dynamicvector.NewCounter(dynamicvector.CounterOpts{Namespace: "ns", Subsystem: "sub", Name: "name"})
+	assert.Equal(t, "ns_sub_name", v.Name())
+}
+
+func TestVector_Series(t *testing.T) {
+	cv := createCounter(0)
+	cv.With(prometheus.Labels{"label2": "b"}).Add(2)
+	cv.With(prometheus.Labels{"label1": "a"}).Inc()
+
+	series, err := cv.Series()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(series))
+	assert.Equal(t, prometheus.Labels{"label1": "a"}, series[0].Labels)
+	assert.Equal(t, float64(1), series[0].Metric.Counter.GetValue())
+	assert.Equal(t, prometheus.Labels{"label2": "b"}, series[1].Labels)
+	assert.Equal(t, float64(2), series[1].Metric.Counter.GetValue())
+	assert.False(t, series[0].Created.IsZero())
+	assert.False(t, series[0].Created.After(series[0].LastEdit))
+
+	m, _ := dynamicvector.ParseMatcher("label2=b")
+	series, err = cv.Series(m)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(series))
+	assert.Equal(t, prometheus.Labels{"label2": "b"}, series[0].Labels)
+}
+
+func TestVector_Series_Expire(t *testing.T) {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "counter", Expire: 50 * time.Millisecond})
+	cv.With(prometheus.Labels{"label1": "a"})
+
+	time.Sleep(100 * time.Millisecond)
+	series, err := cv.Series()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(series))
+}
+
+func TestVector_DeleteMatching(t *testing.T) {
+	cv := createCounter(0)
+	for _, v := range []string{"a", "b", "c"} {
+		cv.With(prometheus.Labels{"label1": v}).Inc()
+	}
+
+	m, _ := dynamicvector.ParseMatcher(`label1!="b"`)
+	deleted, err := cv.DeleteMatching(m)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	series, _ := cv.Series()
+	assert.Equal(t, 1, len(series))
+	assert.Equal(t, prometheus.Labels{"label1": "b"}, series[0].Labels)
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+// Package statsd emit dynamicvector metrics to StatsD or DogStatsD agent.
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector"
+)
+
+// Config is configuration for Emitter.
+type Config struct {
+	// Addr is the agent UDP address, for example localhost:8125. Mandatory!
+	Addr string
+
+	// Prefix is prepended to every metric name, separated by dot.
+	Prefix string
+
+	// HistogramType is the StatsD type used for histogram, "h" or "d". Default is "h".
+	HistogramType string
+
+	// PacketSize is maximum UDP packet size. Zero means DefaultPacketSize.
+	PacketSize int
+
+	// ErrorHandler is called when flush in Run fail. Nil means error is ignored.
+	ErrorHandler func(error)
+}
+
+// Emitter send vectors to StatsD agent with labels as DogStatsD tags. Counter is sent as delta since
+// previous flush, gauge as its value. Histogram does not keep its observations, so every observation
+// since previous flush is sent as the upper bound of its bucket using sample rate. Observations above
+// highest bucket are sent as the highest bound.
+type Emitter struct {
+	cfg     Config
+	vectors dynamicvector.VectorLister
+	conn    net.Conn
+
+	mtx  sync.Mutex
+	prev map[string][]float64 // cumulative values on previous flush, by series identity.
+}
+
+var tagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// NewEmitter will create new Emitter.
+func NewEmitter(cfg Config, vl dynamicvector.VectorLister) (*Emitter, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("statsd: Addr is mandatory")
+	}
+	if cfg.HistogramType == "" {
+		cfg.HistogramType = "h"
+	}
+	if cfg.HistogramType != "h" && cfg.HistogramType != "d" {
+		return nil, errors.New("statsd: HistogramType must be h or d")
+	}
+
+	conn, err := net.Dial("udp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Emitter{
+		cfg:     cfg,
+		vectors: vl,
+		conn:    conn,
+		prev:    make(map[string][]float64),
+	}, nil
+}
+
+// Flush send all live metrics once. When it fail, series that are not sent keep their previous
+// value, so their delta is not lost on next flush.
+func (e *Emitter) Flush() error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	next := make(map[string][]float64)
+	err := e.write(NewBatchWriter(e.conn, e.cfg.PacketSize), next)
+	if err != nil {
+		for id, cum := range e.prev {
+			if _, ok := next[id]; !ok {
+				next[id] = cum
+			}
+		}
+	}
+	e.prev = next
+
+	return err
+}
+
+// write send lines of all live metrics through w. Cumulative value of a series is recorded in next
+// only after the packet holding its lines is sent. Series whose lines span packets is recorded
+// after its last packet.
+func (e *Emitter) write(w *BatchWriter, next map[string][]float64) error {
+	type buffered struct {
+		id  string
+		cum []float64
+	}
+	var pending []buffered
+	commit := func() {
+		for _, b := range pending {
+			next[b.id] = b.cum
+		}
+		pending = pending[:0]
+	}
+
+	for _, v := range e.vectors.Vectors() {
+		series, err := v.Series()
+		if err != nil {
+			return err
+		}
+
+		name := v.Name()
+		if e.cfg.Prefix != "" {
+			name = e.cfg.Prefix + "." + name
+		}
+		for _, s := range series {
+			tags := formatTags(s.Labels)
+			id := name + tags
+			for _, line := range e.lines(name, tags, s.Metric, e.prev[id]) {
+				sent := w.sent
+				if err := w.WriteLine(line); err != nil {
+					return err
+				}
+				if w.sent != sent {
+					commit()
+				}
+			}
+			pending = append(pending, buffered{id: id, cum: cumulative(s.Metric)})
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	commit()
+
+	return nil
+}
+
+// Run will flush every interval until ctx is done.
+func (e *Emitter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Flush(); err != nil && e.cfg.ErrorHandler != nil {
+				e.cfg.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// Close close connection to agent.
+func (e *Emitter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *Emitter) lines(name, tags string, m *dto.Metric, prev []float64) [][]byte {
+	cur := cumulative(m)
+	switch {
+	case m.Gauge != nil:
+		return [][]byte{formatLine(name, m.Gauge.GetValue(), "g", 1, tags)}
+	case m.Counter != nil || m.Untyped != nil:
+		if d := delta(cur, prev, 0); d != 0 {
+			return [][]byte{formatLine(name, d, "c", 1, tags)}
+		}
+	case m.Histogram != nil:
+		var lines [][]byte
+		bounds := bucketBounds(m.Histogram)
+
+		// cur is count followed by cumulative bucket counts, sorted by bound.
+		below := float64(0)
+		for i, bound := range bounds {
+			n := delta(cur, prev, i+1)
+			if n-below > 0 {
+				lines = append(lines, formatLine(name, bound, e.cfg.HistogramType, 1/(n-below), tags))
+			}
+			below = n
+		}
+
+		if over := delta(cur, prev, 0) - below; over > 0 {
+			value := float64(0)
+			if len(bounds) > 0 {
+				value = bounds[len(bounds)-1]
+			}
+			lines = append(lines, formatLine(name, value, e.cfg.HistogramType, 1/over, tags))
+		}
+		return lines
+	}
+
+	return nil
+}
+
+// cumulative return cumulative values of counter or histogram, which are used to compute delta.
+func cumulative(m *dto.Metric) []float64 {
+	switch {
+	case m.Counter != nil:
+		return []float64{m.Counter.GetValue()}
+	case m.Untyped != nil:
+		return []float64{m.Untyped.GetValue()}
+	case m.Histogram != nil:
+		buckets := sortedBuckets(m.Histogram)
+		values := make([]float64, 0, len(buckets)+1)
+		values = append(values, float64(m.Histogram.GetSampleCount()))
+		for _, b := range buckets {
+			values = append(values, float64(b.GetCumulativeCount()))
+		}
+		return values
+	}
+
+	return nil
+}
+
+// delta return difference between cur[i] and prev[i]. Missing or bigger previous value means the
+// series is new or reset, so the whole current value is returned.
+func delta(cur, prev []float64, i int) float64 {
+	if len(prev) != len(cur) || prev[i] > cur[i] {
+		return cur[i]
+	}
+
+	return cur[i] - prev[i]
+}
+
+func sortedBuckets(h *dto.Histogram) []*dto.Bucket {
+	buckets := append([]*dto.Bucket(nil), h.Bucket...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].GetUpperBound() < buckets[j].GetUpperBound() })
+
+	return buckets
+}
+
+func bucketBounds(h *dto.Histogram) []float64 {
+	var bounds []float64
+	for _, b := range sortedBuckets(h) {
+		bounds = append(bounds, b.GetUpperBound())
+	}
+
+	return bounds
+}
+
+// formatTags return DogStatsD tags sorted by name, for example |#code:200,path:/.
+func formatTags(lbl map[string]string) string {
+	if len(lbl) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(lbl))
+	for name := range lbl {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("|#")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(tagEscaper.Replace(name))
+		b.WriteByte(':')
+		b.WriteString(tagEscaper.Replace(lbl[name]))
+	}
+
+	return b.String()
+}
+
+func formatLine(name string, value float64, typ string, rate float64, tags string) []byte {
+	b := make([]byte, 0, len(name)+len(tags)+32)
+	b = append(b, name...)
+	b = append(b, ':')
+	b = strconv.AppendFloat(b, value, 'f', -1, 64)
+	b = append(b, '|')
+	b = append(b, typ...)
+	if rate < 1 {
+		b = append(b, "|@"...)
+		b = strconv.AppendFloat(b, rate, 'g', 6, 64)
+	}
+
+	return append(b, tags...)
+}
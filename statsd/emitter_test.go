@@ -0,0 +1,165 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd_test
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/statsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmitter_Flush(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer agent.Close()
+
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "requests_total"})
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "temperature"})
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "latency", Buckets: []float64{1, 10}})
+
+	e, err := statsd.NewEmitter(statsd.Config{Addr: agent.LocalAddr().String(), Prefix: "app", HistogramType: "d"},
+		dynamicvector.VectorList{cv.Vector, gv.Vector, hv.Vector})
+	assert.NoError(t, err)
+	defer e.Close()
+
+	c := cv.With(prometheus.Labels{"code": "200", "path": "/a,b"})
+	c.Add(3)
+	gv.With(prometheus.Labels{}).Set(21.5)
+	h := hv.With(prometheus.Labels{"path": "/"})
+	h.Observe(0.5)
+	h.Observe(5)
+	h.Observe(5)
+	h.Observe(50)
+
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, []string{
+		"app.latency:10|d|@0.5|#path:/",
+		"app.latency:10|d|@1|#path:/",
+		"app.latency:1|d|@1|#path:/",
+		"app.requests_total:3|c|#code:200,path:/a_b",
+		"app.temperature:21.5|g",
+	}, readLines(t, agent))
+
+	c.Add(2)
+	h.Observe(0.1)
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, []string{
+		"app.latency:1|d|@1|#path:/",
+		"app.requests_total:2|c|#code:200,path:/a_b",
+		"app.temperature:21.5|g",
+	}, readLines(t, agent))
+}
+
+func TestEmitter_Flush_KeepPrevious(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer agent.Close()
+
+	bv := dynamicvector.NewVector(dynamicvector.Opts{Name: "broken"}, newBrokenMetric)
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "requests_total"})
+	e, err := statsd.NewEmitter(statsd.Config{Addr: agent.LocalAddr().String()}, dynamicvector.VectorList{bv, cv.Vector})
+	assert.NoError(t, err)
+	defer e.Close()
+
+	c := cv.With(prometheus.Labels{})
+	c.Add(3)
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, []string{"requests_total:3|c"}, readLines(t, agent))
+
+	// broken vector fail before counter is reached, counter must keep its previous value.
+	bv.With(prometheus.Labels{})
+	c.Add(2)
+	assert.Error(t, e.Flush())
+
+	bv.Reset()
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, []string{"requests_total:2|c"}, readLines(t, agent))
+}
+
+func TestEmitter_Flush_WriteError(t *testing.T) {
+	// nobody listen on addr, so agent answer with port unreachable and next write fail.
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := agent.LocalAddr().String()
+	agent.Close()
+
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "requests_total"})
+	e, err := statsd.NewEmitter(statsd.Config{Addr: addr}, dynamicvector.VectorList{cv.Vector})
+	assert.NoError(t, err)
+	defer e.Close()
+
+	c := cv.With(prometheus.Labels{})
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.Inc()
+		if e.Flush() != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("write to closed port does not fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// delta of the failed packet is sent once agent is back.
+	agent, err = net.ListenPacket("udp", addr)
+	assert.NoError(t, err)
+	defer agent.Close()
+
+	c.Add(2)
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, []string{"requests_total:3|c"}, readLines(t, agent))
+}
+
+func TestNewEmitter_Error(t *testing.T) {
+	_, err := statsd.NewEmitter(statsd.Config{}, dynamicvector.VectorList{})
+	assert.Error(t, err)
+
+	_, err = statsd.NewEmitter(statsd.Config{Addr: "127.0.0.1:8125", HistogramType: "x"}, dynamicvector.VectorList{})
+	assert.Error(t, err)
+}
+
+// readLines read single packet and return its lines sorted, with sample rate of 1 written as @1.
+func readLines(t *testing.T, conn net.PacketConn) []string {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(buf[:n]), "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "|d") && !strings.Contains(line, "|@") {
+			lines[i] = strings.Replace(line, "|d", "|d|@1", 1)
+		}
+	}
+	sort.Strings(lines)
+
+	return lines
+}
+
+// brokenMetric is a metric that always fail to write.
+type brokenMetric struct {
+	desc *prometheus.Desc
+}
+
+func newBrokenMetric(vec *dynamicvector.Vector, labelValues []string) dynamicvector.Metric {
+	return &brokenMetric{desc: vec.MetricDesc(labelValues)}
+}
+
+func (m *brokenMetric) Desc() *prometheus.Desc  { return m.desc }
+func (m *brokenMetric) Write(*dto.Metric) error { return errors.New("broken") }
+func (m *brokenMetric) LastEdit() time.Time     { return time.Now() }
@@ -0,0 +1,100 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/internal/bridge"
+)
+
+// Sample is a single parsed StatsD or DogStatsD line.
+type Sample struct {
+	// Name is metric name, sanitized to be valid prometheus metric name.
+	Name string
+
+	// Type is StatsD type, one of "c", "g", "ms", "h" or "d".
+	Type string
+
+	// Values contain one or more values. DogStatsD allow multiple values separated by colon.
+	Values []float64
+
+	// Relative is true for gauge with explicit sign, which mean add instead of set.
+	Relative bool
+
+	// SampleRate is the sample rate, 1 if not set.
+	SampleRate float64
+
+	// Labels are DogStatsD tags. Tags without value are ignored.
+	Labels prometheus.Labels
+}
+
+// ParseLine will parse a single StatsD or DogStatsD line, for example
+// page.views:1|c|@0.5|#path:/index,env:prod.
+func ParseLine(line string) (Sample, error) {
+	s := Sample{SampleRate: 1}
+
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return s, fmt.Errorf("statsd: invalid line %q: missing name", line)
+	}
+	s.Name = bridge.SanitizeName(line[:i])
+
+	parts := strings.Split(line[i+1:], "|")
+	if len(parts) < 2 {
+		return s, fmt.Errorf("statsd: invalid line %q: missing type", line)
+	}
+
+	s.Type = parts[1]
+	switch s.Type {
+	case "c", "g", "ms", "h", "d":
+	default:
+		return s, fmt.Errorf("statsd: invalid line %q: unsupported type %q", line, s.Type)
+	}
+
+	for _, raw := range strings.Split(parts[0], ":") {
+		if s.Type == "g" && (strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-")) {
+			s.Relative = true
+		}
+
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return s, fmt.Errorf("statsd: invalid line %q: %s", line, err)
+		}
+		s.Values = append(s.Values, v)
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return s, fmt.Errorf("statsd: invalid line %q: invalid sample rate %q", line, part[1:])
+			}
+			s.SampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			s.Labels = parseTags(part[1:])
+		}
+	}
+
+	return s, nil
+}
+
+func parseTags(s string) prometheus.Labels {
+	lbl := make(prometheus.Labels)
+	for _, tag := range strings.Split(s, ",") {
+		i := strings.IndexByte(tag, ':')
+		if i <= 0 || i == len(tag)-1 {
+			continue
+		}
+		lbl[bridge.SanitizeLabel(tag[:i])] = tag[i+1:]
+	}
+
+	return lbl
+}
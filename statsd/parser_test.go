@@ -0,0 +1,44 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector/statsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	s, err := statsd.ParseLine("page.views:2|c|@0.5|#path:/index,env:prod,flag")
+	assert.NoError(t, err)
+	assert.Equal(t, statsd.Sample{
+		Name:       "page_views",
+		Type:       "c",
+		Values:     []float64{2},
+		SampleRate: 0.5,
+		Labels:     prometheus.Labels{"path": "/index", "env": "prod"},
+	}, s)
+
+	s, err = statsd.ParseLine("temp:-3|g")
+	assert.NoError(t, err)
+	assert.True(t, s.Relative)
+	assert.Equal(t, []float64{-3}, s.Values)
+	assert.Nil(t, s.Labels)
+
+	s, err = statsd.ParseLine("latency:1:2.5:3|d|#host.name:a")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2.5, 3}, s.Values)
+	assert.Equal(t, prometheus.Labels{"host_name": "a"}, s.Labels)
+}
+
+func TestParseLine_Error(t *testing.T) {
+	for _, line := range []string{"", "name", ":1|c", "name:1", "name:1|s", "name:x|c", "name:1|c|@2", "name:1|c|@x"} {
+		_, err := statsd.ParseLine(line)
+		assert.Error(t, err, line)
+	}
+}
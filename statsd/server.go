@@ -0,0 +1,223 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/rolandhawk/dynamicvector/internal/bridge"
+)
+
+// ServerOpts is an option for creating Server.
+type ServerOpts struct {
+	// Namespace is prepended to every metric name.
+	Namespace string
+
+	// Buckets is used for every histogram, timer and distribution. Default is prometheus.DefBuckets.
+	Buckets []float64
+
+	// Expire and MaxLength are applied to every created vector, see dynamicvector.Opts.
+	Expire    time.Duration
+	MaxLength int
+
+	// ErrorHandler is called with every line that can not be handled. Nil means error is ignored.
+	ErrorHandler func(error)
+}
+
+// Server receive StatsD and DogStatsD lines and feed them into dynamic vectors. A vector is
+// created and registered on demand for every metric name, with DogStatsD tags as its labels.
+// Counter become Counter, gauge become Gauge, and timer, histogram and distribution become Histogram.
+// Sample rate is applied to counter value and to the number of histogram observations.
+type Server struct {
+	opts   ServerOpts
+	family *dynamicvector.Family
+
+	closers bridge.Closers
+}
+
+// NewServer will create new Server that register created vectors to reg.
+func NewServer(opts ServerOpts, reg prometheus.Registerer) *Server {
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+
+	family := dynamicvector.NewFamily(dynamicvector.FamilyOpts{
+		Default: dynamicvector.Opts{
+			Namespace: opts.Namespace,
+			Buckets:   opts.Buckets,
+			Expire:    opts.Expire,
+			MaxLength: opts.MaxLength,
+			Unchecked: true,
+		},
+		Customize: func(typ string, o *dynamicvector.Opts) {
+			o.Help = fmt.Sprintf("StatsD %s %s", typ, o.Name)
+		},
+	}, reg)
+
+	return &Server{
+		opts:   opts,
+		family: family,
+	}
+}
+
+// Handle parse and apply a single line.
+func (s *Server) Handle(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		// DogStatsD events and service checks are not metrics.
+		return nil
+	}
+
+	sample, err := ParseLine(line)
+	if err != nil {
+		return err
+	}
+
+	if err := s.apply(sample); err != nil {
+		return fmt.Errorf("statsd: %s", err)
+	}
+	return nil
+}
+
+func (s *Server) apply(sample Sample) error {
+	switch sample.Type {
+	case "c":
+		for _, v := range sample.Values {
+			if v < 0 {
+				return fmt.Errorf("counter %s can not decrease", sample.Name)
+			}
+		}
+
+		c, err := s.family.Counter(sample.Name, sample.Labels)
+		if err != nil {
+			return err
+		}
+		for _, v := range sample.Values {
+			c.Add(v / sample.SampleRate)
+		}
+	case "g":
+		g, err := s.family.Gauge(sample.Name, sample.Labels)
+		if err != nil {
+			return err
+		}
+		for _, v := range sample.Values {
+			if sample.Relative {
+				g.Add(v)
+			} else {
+				g.Set(v)
+			}
+		}
+	default:
+		h, err := s.family.Histogram(sample.Name, sample.Labels)
+		if err != nil {
+			return err
+		}
+
+		// sampled observation is observed 1/rate times, like statsd_exporter does.
+		n := int(math.Round(1 / sample.SampleRate))
+		for _, v := range sample.Values {
+			if sample.Type == "ms" {
+				v = v / 1000
+			}
+			for i := 0; i < n; i++ {
+				h.Observe(v)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Vectors implement dynamicvector.VectorLister.
+func (s *Server) Vectors() []*dynamicvector.Vector {
+	return s.family.Vectors()
+}
+
+// GC run GC on every vector.
+func (s *Server) GC() dynamicvector.GCStat {
+	return s.family.GC()
+}
+
+// ListenAndServe listen on network address and serve it until Close is called. Network can be
+// "udp", "unixgram", "tcp" or "unix".
+func (s *Server) ListenAndServe(network, addr string) error {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		conn, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return err
+		}
+		return s.ServePacket(conn)
+	case "tcp", "tcp4", "tcp6", "unix":
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return err
+		}
+		return s.Serve(l)
+	}
+
+	return fmt.Errorf("statsd: unsupported network %s", network)
+}
+
+// ServePacket read datagrams from conn. Every datagram can contain multiple lines.
+func (s *Server) ServePacket(conn net.PacketConn) error {
+	s.closers.Track(conn, true)
+	defer s.closers.Track(conn, false)
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			s.handle(line)
+		}
+	}
+}
+
+// Serve accept stream connections from l and read newline separated lines from them.
+func (s *Server) Serve(l net.Listener) error {
+	s.closers.Track(l, true)
+	defer s.closers.Track(l, false)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.closers.Track(conn, true)
+
+		go func() {
+			defer s.closers.Track(conn, false)
+			defer conn.Close()
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				s.handle(scanner.Text())
+			}
+		}()
+	}
+}
+
+// Close stop all listeners and connections.
+func (s *Server) Close() error {
+	return s.closers.Close()
+}
+
+func (s *Server) handle(line string) {
+	if err := s.Handle(line); err != nil && s.opts.ErrorHandler != nil {
+		s.opts.ErrorHandler(err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/statsd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Handle(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	s := statsd.NewServer(statsd.ServerOpts{Namespace: "statsd", Buckets: []float64{0.1, 1}}, reg)
+
+	assert.NoError(t, s.Handle("requests:1|c|@0.5|#code:200"))
+	assert.NoError(t, s.Handle("requests:1|c|#code:500,path:/"))
+	assert.NoError(t, s.Handle("temperature:20|g"))
+	assert.NoError(t, s.Handle("temperature:+2|g"))
+	assert.NoError(t, s.Handle("latency:50:500|ms|#path:/"))
+	assert.NoError(t, s.Handle("_e{5,4}:title|text"))
+
+	assert.Error(t, s.Handle("requests:1|g"))
+	assert.Error(t, s.Handle("requests:-1|c"))
+	assert.Error(t, s.Handle("invalid"))
+
+	mfs := gather(t, reg)
+	assert.Equal(t, 3, len(mfs))
+	assert.Equal(t, 2, len(mfs["statsd_requests"].Metric))
+	assert.Equal(t, float64(22), mfs["statsd_temperature"].Metric[0].Gauge.GetValue())
+	assert.Equal(t, uint64(2), mfs["statsd_latency"].Metric[0].Histogram.GetSampleCount())
+	assert.Equal(t, 0.55, mfs["statsd_latency"].Metric[0].Histogram.GetSampleSum())
+
+	var total float64
+	for _, m := range mfs["statsd_requests"].Metric {
+		total += m.Counter.GetValue()
+	}
+	assert.Equal(t, float64(3), total)
+	assert.Equal(t, 3, len(s.Vectors()))
+}
+
+func TestServer_SampleRate(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	s := statsd.NewServer(statsd.ServerOpts{Buckets: []float64{0.1, 1}}, reg)
+
+	assert.NoError(t, s.Handle("latency:320|ms|@0.1"))
+	assert.NoError(t, s.Handle("size:2|h|@0.3"))
+
+	mfs := gather(t, reg)
+	assert.Equal(t, uint64(10), mfs["latency"].Metric[0].Histogram.GetSampleCount())
+	assert.InDelta(t, 3.2, mfs["latency"].Metric[0].Histogram.GetSampleSum(), 1e-9)
+	assert.Equal(t, uint64(3), mfs["size"].Metric[0].Histogram.GetSampleCount())
+}
+
+func TestServer_MaxLength(t *testing.T) {
+	s := statsd.NewServer(statsd.ServerOpts{MaxLength: 1}, nil)
+
+	assert.NoError(t, s.Handle("requests:1|c|#code:200"))
+	assert.NoError(t, s.Handle("requests:1|c|#code:500"))
+	assert.Error(t, s.Handle("requests:1|c|#path:/"))
+	assert.True(t, s.GC().LimitExceeded)
+}
+
+func TestServer_Serve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	reg := prometheus.NewRegistry()
+	s := statsd.NewServer(statsd.ServerOpts{}, reg)
+	defer s.Close()
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.ServePacket(udp)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(tcp)
+
+	unix, err := net.Listen("unix", filepath.Join(dir, "statsd.sock"))
+	assert.NoError(t, err)
+	go s.Serve(unix)
+
+	for _, addr := range []net.Addr{udp.LocalAddr(), tcp.Addr(), unix.Addr()} {
+		conn, err := net.Dial(addr.Network(), addr.String())
+		assert.NoError(t, err)
+		conn.Write([]byte("requests:1|c|#network:" + addr.Network() + "\nrequests:1|c\n"))
+		conn.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if mf, ok := gather(t, reg)["requests"]; ok && len(mf.Metric) == 4 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("not all lines are received: %v", gather(t, reg))
+}
+
+func gather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	mfs, err := g.Gather()
+	assert.NoError(t, err)
+
+	res := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		res[mf.GetName()] = mf
+	}
+
+	return res
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd
+
+import (
+	"io"
+)
+
+// DefaultPacketSize is default maximum packet size. It is recommended value for DogStatsD over UDP.
+const DefaultPacketSize = 1432
+
+// BatchWriter batch newline separated lines into packets no bigger than packet size. A single line
+// that is already bigger than packet size is sent alone.
+type BatchWriter struct {
+	w    io.Writer
+	size int
+	buf  []byte
+	sent int // number of packets sent without error.
+}
+
+// NewBatchWriter will create new BatchWriter that write packet to w, usually an UDP connection.
+// Zero size means DefaultPacketSize.
+func NewBatchWriter(w io.Writer, size int) *BatchWriter {
+	if size <= 0 {
+		size = DefaultPacketSize
+	}
+
+	return &BatchWriter{w: w, size: size, buf: make([]byte, 0, size)}
+}
+
+// WriteLine add line into current packet. Packet is sent when line does not fit in it.
+func (b *BatchWriter) WriteLine(line []byte) error {
+	n := len(line)
+	if len(b.buf) > 0 {
+		n++ // newline separator
+	}
+
+	if len(b.buf) > 0 && len(b.buf)+n > b.size {
+		if err := b.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(b.buf) > 0 {
+		b.buf = append(b.buf, '\n')
+	}
+	b.buf = append(b.buf, line...)
+
+	return nil
+}
+
+// Flush send current packet.
+func (b *BatchWriter) Flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+
+	_, err := b.w.Write(b.buf)
+	b.buf = b.buf[:0]
+	if err == nil {
+		b.sent++
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package statsd_test
+
+import (
+	"testing"
+
+	"github.com/rolandhawk/dynamicvector/statsd"
+	"github.com/stretchr/testify/assert"
+)
+
+type packets []string
+
+func (p *packets) Write(b []byte) (int, error) {
+	*p = append(*p, string(b))
+	return len(b), nil
+}
+
+func TestBatchWriter(t *testing.T) {
+	var p packets
+	w := statsd.NewBatchWriter(&p, 10)
+
+	assert.NoError(t, w.WriteLine([]byte("a:1|c")))
+	assert.NoError(t, w.WriteLine([]byte("b:1|c")))
+	assert.NoError(t, w.WriteLine([]byte("c:10|c")))
+	assert.NoError(t, w.WriteLine([]byte("long:100|c")))
+	assert.NoError(t, w.WriteLine([]byte("d|c")))
+	assert.NoError(t, w.Flush())
+	assert.NoError(t, w.Flush())
+
+	assert.Equal(t, packets{"a:1|c", "b:1|c", "c:10|c", "long:100|c", "d|c"}, p)
+
+	p = nil
+	w = statsd.NewBatchWriter(&p, 0)
+	assert.NoError(t, w.WriteLine([]byte("a:1|c")))
+	assert.NoError(t, w.WriteLine([]byte("b:1|c")))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, packets{"a:1|c\nb:1|c"}, p)
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+	// structPlans cache structPlan per struct type.
+	structPlans sync.Map
+)
+
+// structPlan is a list of fields to extract from a struct type.
+type structPlan struct {
+	fields []structField
+	err    error
+}
+
+type structField struct {
+	index     int
+	name      string
+	omitempty bool
+	stringer  bool // field implement fmt.Stringer
+	ptrString bool // pointer to field implement fmt.Stringer
+}
+
+// StructLabels return labels from fields of struct s that have label tag, such as
+// `label:"method"`. s can be a struct or pointer to struct. Supported field types are string,
+// integer, bool and fmt.Stringer. With `label:"name,omitempty"` zero value is not set as label,
+// and `label:"-"` ignore the field. Extraction plan of every struct type is cached.
+func StructLabels(s interface{}) (prometheus.Labels, error) {
+	rv := reflect.ValueOf(s)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a struct", s)
+	}
+
+	plan := getStructPlan(rv.Type())
+	if plan.err != nil {
+		return nil, plan.err
+	}
+
+	labels := make(prometheus.Labels, len(plan.fields))
+	for _, f := range plan.fields {
+		fv := rv.Field(f.index)
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		labels[f.name] = f.format(fv)
+	}
+
+	return labels, nil
+}
+
+// GetMetricWithStruct behave like GetMetricWith with labels from StructLabels.
+func (v *Vector) GetMetricWithStruct(s interface{}) (prometheus.Metric, error) {
+	labels, err := StructLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	return v.GetMetricWith(labels)
+}
+
+// WithStruct behave like GetMetricWithStruct except it will panic instead when there is an error.
+func (v *Vector) WithStruct(s interface{}) prometheus.Metric {
+	m, err := v.GetMetricWithStruct(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func getStructPlan(t reflect.Type) *structPlan {
+	if plan, ok := structPlans.Load(t); ok {
+		return plan.(*structPlan)
+	}
+
+	plan, _ := structPlans.LoadOrStore(t, newStructPlan(t))
+	return plan.(*structPlan)
+}
+
+func newStructPlan(t reflect.Type) *structPlan {
+	plan := &structPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("label")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f := structField{index: i, name: parts[0]}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range parts[1:] {
+			if opt != "omitempty" {
+				plan.err = fmt.Errorf("%s.%s: unknown label option %q", t, sf.Name, opt)
+				return plan
+			}
+			f.omitempty = true
+		}
+
+		if sf.PkgPath != "" {
+			plan.err = fmt.Errorf("%s.%s: label field must be exported", t, sf.Name)
+			return plan
+		}
+
+		switch {
+		case sf.Type.Implements(stringerType):
+			f.stringer = true
+		case reflect.PtrTo(sf.Type).Implements(stringerType):
+			f.ptrString = true
+		default:
+			switch sf.Type.Kind() {
+			case reflect.String, reflect.Bool,
+				reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			default:
+				plan.err = fmt.Errorf("%s.%s: unsupported label type %s", t, sf.Name, sf.Type)
+				return plan
+			}
+		}
+
+		plan.fields = append(plan.fields, f)
+	}
+
+	return plan
+}
+
+// format return string value of field.
+func (f structField) format(v reflect.Value) string {
+	switch {
+	case f.stringer:
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return ""
+		}
+		return v.Interface().(fmt.Stringer).String()
+	case f.ptrString:
+		if v.CanAddr() {
+			return v.Addr().Interface().(fmt.Stringer).String()
+		}
+		// copy so the value is addressable.
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface().(fmt.Stringer).String()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	default:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2017 Roland Rifandi Utama
+//
+// This software may be modified and distributed under the terms
+// of the MIT license.  See the LICENSE file for details.
+
+package dynamicvector_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rolandhawk/dynamicvector"
+	"github.com/stretchr/testify/assert"
+)
+
+type method int
+
+func (m method) String() string {
+	return [...]string{"GET", "POST"}[m]
+}
+
+type region struct {
+	name string
+}
+
+func (r *region) String() string {
+	return r.name
+}
+
+type request struct {
+	Method  method `label:"method"`
+	Code    int    `label:"code"`
+	Cached  bool   `label:"cached"`
+	User    string `label:"user,omitempty"`
+	Retry   uint8  `label:",omitempty"`
+	Region  region `label:"region,omitempty"`
+	Secret  string `label:"-"`
+	Comment string
+}
+
+func TestStructLabels(t *testing.T) {
+	labels, err := dynamicvector.StructLabels(request{Method: 1, Code: 200, Secret: "x", Comment: "y"})
+	assert.NoError(t, err)
+	assert.Equal(t, prometheus.Labels{"method": "POST", "code": "200", "cached": "false"}, labels)
+
+	labels, err = dynamicvector.StructLabels(&request{Code: 404, Cached: true, User: "a", Retry: 3, Region: region{"eu"}})
+	assert.NoError(t, err)
+	assert.Equal(t, prometheus.Labels{"method": "GET", "code": "404", "cached": "true", "user": "a", "Retry": "3", "region": "eu"}, labels)
+}
+
+func TestStructLabels_Error(t *testing.T) {
+	_, err := dynamicvector.StructLabels("not a struct")
+	assert.Error(t, err)
+	_, err = dynamicvector.StructLabels((*request)(nil))
+	assert.Error(t, err)
+
+	_, err = dynamicvector.StructLabels(struct {
+		Values []string `label:"values"`
+	}{})
+	assert.Error(t, err)
+	_, err = dynamicvector.StructLabels(struct {
+		unexported string `label:"unexported"`
+	}{})
+	assert.Error(t, err)
+	_, err = dynamicvector.StructLabels(struct {
+		Name string `label:"name,required"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestCounter_WithStruct(t *testing.T) {
+	cv := createCounter(0)
+	cv.WithStruct(request{Code: 200}).Inc()
+	cv.WithStruct(&request{Code: 200}).Inc()
+
+	series, _ := cv.Series()
+	if assert.Equal(t, 1, len(series)) {
+		assert.Equal(t, prometheus.Labels{"method": "GET", "code": "200", "cached": "false"}, series[0].Labels)
+		assert.Equal(t, 2.0, series[0].Metric.GetCounter().GetValue())
+	}
+
+	_, err := cv.GetMetricWithStruct(1)
+	assert.Error(t, err)
+	assert.Panics(t, func() { cv.WithStruct(nil) })
+}
+
+func TestGaugeHistogram_WithStruct(t *testing.T) {
+	gv := dynamicvector.NewGauge(dynamicvector.GaugeOpts{Name: "g", Help: "help"})
+	gv.WithStruct(request{}).Set(1)
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "h", Help: "help", Buckets: []float64{1}})
+	hv.WithStruct(request{}).Observe(1)
+
+	assert.Equal(t, 1, gv.Length())
+	assert.Equal(t, 1, hv.Length())
+}
+
+func BenchmarkCounter_WithStruct(b *testing.B) {
+	cv := createCounter(0)
+	req := request{Code: 200, User: "a"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cv.WithStruct(&req).Inc()
+	}
+}
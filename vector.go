@@ -9,11 +9,17 @@
 package dynamicvector
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rolandhawk/dynamicvector/internal/ratelimit"
 )
 
 // Metric is an interface that encapsulate prometheus.Metric interface
@@ -24,8 +30,15 @@ type Metric interface {
 	LastEdit() time.Time
 }
 
-// Vector is a dynamicvector that used to keep metrics.
+// Vector is a dynamicvector that used to keep metrics. Vector returned by CurryWith is a view that
+// share metrics with its parent.
 type Vector struct {
+	*vectorState
+	curry prometheus.Labels // labels bound by CurryWith.
+}
+
+type vectorState struct {
+	root        *Vector                                        // vector created by NewVector.
 	opts        Opts                                           // vector options
 	constructor func(vec *Vector, labelValues []string) Metric // constructor to make new metric
 
@@ -33,24 +46,59 @@ type Vector struct {
 	labels       *Labels           // Labels contain information about metric labels.
 	pseudoLength int               // it used when resetting vector that already exceed max length.
 	metrics      map[uint64]Metric // vector metric
-	desc         *prometheus.Desc
+	keysGen      uint64            // incremented whenever label keys change, used by Handle.
+	bytes        int               // estimated memory used by metrics.
+	snapshot     atomic.Value      // *vectorMeta, read without lock by MetricDesc and MetricLabels.
+	updateMtx    sync.Mutex        // serialize Update.
+
+	createLimit *ratelimit.TokenBucket            // limit of metric creation, nil mean no limit.
+	keyLimits   map[string]*ratelimit.TokenBucket // limit of metric creation per label key.
+	throttled   ThrottleStat
+	gcHistory   []GCRecord      // last GC runs, oldest first.
+	creations   int             // number of created metrics, used for sampling call site.
+	sites       map[siteKey]int // number of sampled creations per call site.
+}
+
+// vectorMeta is what metrics need to describe themselves and current options. It is never
+// modified, a new one is stored when label keys or options change.
+type vectorMeta struct {
+	desc  *prometheus.Desc
+	descs map[string]*prometheus.Desc // per label keys Desc, only used when Opts.Unchecked is set.
+	keys  []string
+	opts  Opts // fields that can be changed by Update must be read from here.
 }
 
 // NewVector will create new vector with specified option and metric constructor.
 func NewVector(opts Opts, cons func(v *Vector, labelValues []string) Metric) *Vector {
-	vec := &Vector{
+	vec := &Vector{vectorState: &vectorState{
 		opts:        opts,
 		constructor: cons,
-	}
+		sites:       make(map[siteKey]int),
+	}}
+	vec.root = vec
+	vec.snapshot.Store(&vectorMeta{opts: copyOpts(opts)})
 	vec.reset()
+	vec.initLimits()
+	if opts.Budget != nil {
+		opts.Budget.add(vec, opts.BudgetReserve, opts.BudgetWeight)
+	}
 
 	return vec
 }
 
 // GetMetricWith returns the Metric for the given Labels map (the label names must match those of
 // the VariableLabels in Desc). If that label map is accessed for the first time, a new Metric is created.
-// Return error if maxLen is exceeded.
+// Return error if maxLen, MaxBytes, Budget or creation rate is exceeded.
 func (v *Vector) GetMetricWith(labels prometheus.Labels) (prometheus.Metric, error) {
+	labels, err := v.curryLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.getMetricWith(labels)
+}
+
+func (v *Vector) getMetricWith(labels prometheus.Labels) (prometheus.Metric, error) {
 	v.mtx.RLock()
 	metric := v.get(labels)
 	v.mtx.RUnlock()
@@ -59,18 +107,89 @@ func (v *Vector) GetMetricWith(labels prometheus.Labels) (prometheus.Metric, err
 		return metric, nil
 	}
 
+	// budget is acquired before locking because it may evict metric from any vector.
+	if v.opts.Budget != nil {
+		if err := v.opts.Budget.acquire(v.root); err != nil {
+			return nil, fmt.Errorf("vector with %s: %s", v.meta().desc.String(), err)
+		}
+	}
+
 	v.mtx.Lock()
 	defer v.mtx.Unlock()
 
 	metric = v.get(labels)
 	if metric != nil {
+		v.release(1)
 		return metric, nil
 	}
+	throttleErr := v.throttle(labels)
+	if throttleErr != nil {
+		if v.opts.OverflowValue == "" {
+			v.release(1)
+			return nil, throttleErr
+		}
+
+		labels = v.overflowLabels(labels)
+		if metric = v.get(labels); metric != nil {
+			v.release(1)
+			return metric, nil
+		}
+	}
 	if v.exceedMaxLength() {
-		return nil, fmt.Errorf("vector with %s exceed length limit", v.desc.String())
+		v.release(1)
+		return nil, fmt.Errorf("vector with %s exceed length limit", v.meta().desc.String())
+	}
+
+	values, newKeys := v.labels.values(labels)
+	m := v.constructor(v.root, values)
+	if v.exceedMaxBytes(m, newKeys) {
+		v.release(1)
+		return nil, fmt.Errorf("vector with %s exceed memory limit", v.meta().desc.String())
+	}
+
+	if throttleErr == nil {
+		v.takeToken(labels)
+	}
+	v.create(labels, values, newKeys, m)
+	return m, nil
+}
+
+// GetMetricWithLabelValues behave like GetMetricWith with label values ordered as
+// Opts.LabelNames. Existing metric is found without building prometheus.Labels.
+func (v *Vector) GetMetricWithLabelValues(values ...string) (prometheus.Metric, error) {
+	if len(v.curry) > 0 {
+		labels, err := v.curryLabelValues(values)
+		if err != nil {
+			return nil, err
+		}
+		return v.getMetricWith(labels)
+	}
+	if len(values) != len(v.opts.LabelNames) {
+		return nil, fmt.Errorf("vector with %s: expected %d label values but got %d", v.meta().desc.String(), len(v.opts.LabelNames), len(values))
+	}
+
+	v.mtx.RLock()
+	metric := v.metrics[v.labels.HashValues(values)]
+	v.mtx.RUnlock()
+
+	if metric != nil {
+		return metric, nil
+	}
+
+	labels := make(prometheus.Labels, len(values))
+	for i, name := range v.opts.LabelNames {
+		labels[name] = values[i]
 	}
+	return v.getMetricWith(labels)
+}
 
-	return v.create(labels), nil
+// WithLabelValues behave like GetMetricWithLabelValues except it will panic instead when there is an error.
+func (v *Vector) WithLabelValues(values ...string) prometheus.Metric {
+	m, err := v.GetMetricWithLabelValues(values...)
+	if err != nil {
+		panic(err)
+	}
+	return m
 }
 
 // With behave like GetMetricWith except it will panic instead when there is an error.
@@ -96,11 +215,75 @@ func (v *Vector) Reset() {
 	v.mtx.Lock()
 	defer v.mtx.Unlock()
 
+	v.release(len(v.metrics))
 	v.reset()
 }
 
+// Opts return a copy of current options of this vector.
+func (v *Vector) Opts() Opts {
+	return copyOpts(v.meta().opts)
+}
+
+// Update change options of this vector at runtime. Only Help, ConstLabels, Buckets, Expire,
+// MaxLength and MaxBytes can be changed, otherwise error is returned and nothing is changed.
+// Buckets must be sorted without duplicate, they only apply to new metrics. Changing MaxLength
+// clear the exceeded state of vector. Vector that is registered to prometheus.Registry should be
+// Unchecked if Help or ConstLabels is changed. Updates are applied one at a time, fn is called with
+// a copy of current options without holding vector lock, it must not call Update.
+func (v *Vector) Update(fn func(opts *Opts)) error {
+	v.updateMtx.Lock()
+	defer v.updateMtx.Unlock()
+
+	cur := v.meta().opts
+	next := copyOpts(cur)
+	fn(&next)
+
+	fixed := copyOpts(cur)
+	fixed.Help = next.Help
+	fixed.ConstLabels = next.ConstLabels
+	fixed.Buckets = next.Buckets
+	fixed.Expire = next.Expire
+	fixed.MaxLength = next.MaxLength
+	fixed.MaxBytes = next.MaxBytes
+	if !reflect.DeepEqual(fixed, next) {
+		return errors.New("only Help, ConstLabels, Buckets, Expire, MaxLength and MaxBytes can be updated")
+	}
+	for i := 1; i < len(next.Buckets); i++ {
+		if next.Buckets[i] <= next.Buckets[i-1] {
+			return errors.New("Buckets must be sorted without duplicate")
+		}
+	}
+	// fn may keep next, it is copied again so the stored options are never modified.
+	next = copyOpts(next)
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if next.MaxLength != cur.MaxLength {
+		v.pseudoLength = 0
+	}
+	v.labels.Constant = next.ConstLabels
+
+	descs := make(map[string]*prometheus.Desc, len(v.meta().descs))
+	for key := range v.meta().descs {
+		var names []string
+		if key != "" {
+			names = strings.Split(key, "\xff")
+		}
+		descs[key] = newDesc(next, names)
+	}
+	v.updateMeta(next, descs)
+
+	return nil
+}
+
 // Delete will delete metric that have exact match labels from vector.
 func (v *Vector) Delete(l prometheus.Labels) bool {
+	l, err := v.curryLabels(l)
+	if err != nil {
+		return false
+	}
+
 	v.mtx.Lock()
 	defer v.mtx.Unlock()
 
@@ -109,8 +292,10 @@ func (v *Vector) Delete(l prometheus.Labels) bool {
 	}
 
 	h := v.labels.Hash(l)
-	_, found := v.metrics[h]
-	delete(v.metrics, h)
+	m, found := v.metrics[h]
+	if found {
+		v.remove(h, m)
+	}
 
 	return found
 }
@@ -131,12 +316,16 @@ func (v *Vector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
-// Describe implement prometheus.Collector.
+// Describe implement prometheus.Collector. It send nothing when Opts.Unchecked is set.
 func (v *Vector) Describe(ch chan<- *prometheus.Desc) {
+	if v.opts.Unchecked {
+		return
+	}
+
 	v.mtx.RLock()
 	defer v.mtx.RUnlock()
 
-	ch <- v.desc
+	ch <- v.meta().desc
 }
 
 // GC will do housekeeping work related to this metrics and return
@@ -147,10 +336,13 @@ func (v *Vector) GC() GCStat {
 	v.mtx.Lock()
 	defer v.mtx.Unlock()
 
+	start := time.Now()
+	defer func() { v.recordGC(start, stat) }()
+
 	// delete expired metrics
 	for h, m := range v.metrics {
 		if v.isExpire(m.LastEdit()) {
-			delete(v.metrics, h)
+			v.remove(h, m)
 			stat.Deleted++
 		}
 	}
@@ -158,6 +350,7 @@ func (v *Vector) GC() GCStat {
 	// delete all metrics for vector that exceed MaxLength
 	if v.exceedMaxLength() {
 		v.pseudoLength = v.Length()
+		v.release(len(v.metrics))
 		v.reset()
 		stat.Deleted = stat.Deleted + v.pseudoLength
 		stat.LimitExceeded = true
@@ -174,43 +367,197 @@ func (v *Vector) get(l prometheus.Labels) prometheus.Metric {
 	return v.metrics[v.labels.Hash(l)]
 }
 
-func (v *Vector) create(l prometheus.Labels) prometheus.Metric {
-	oldLen := len(v.labels.Keys)
-	labelValues := v.labels.PromLabelsToValues(l)
+// create add metric made for labels l. values and newKeys are returned by Labels.values.
+func (v *Vector) create(l prometheus.Labels, labelValues, newKeys []string, metric Metric) {
+	for _, key := range newKeys {
+		v.labels.addKey(key)
+	}
+	changed := len(newKeys) > 0
+	if changed {
+		v.keysGen++
+	}
 
-	if oldLen != len(v.labels.Keys) {
-		v.desc = v.newDesc()
+	descs := v.meta().descs
+	if v.opts.Unchecked {
+		key, names := setKeys(v.labels.Keys, labelValues)
+		if _, ok := descs[key]; !ok {
+			next := make(map[string]*prometheus.Desc, len(descs)+1)
+			for k, desc := range descs {
+				next[k] = desc
+			}
+			next[key] = newDesc(v.meta().opts, names)
+			descs, changed = next, true
+		}
 	}
+	if changed {
+		v.updateMeta(v.meta().opts, descs)
+	}
+
+	v.sampleCaller(labelValues)
 
-	metric := v.constructor(v, labelValues)
 	v.metrics[v.labels.Hash(l)] = metric
+	v.bytes += metricSize(metric)
+}
+
+// MetricDesc return Desc for metric with given label values. Metric constructed by this vector
+// should use it as its Desc. It is safe to call without holding any lock.
+func (v *Vector) MetricDesc(values []string) *prometheus.Desc {
+	meta := v.meta()
+	if !v.opts.Unchecked {
+		return meta.desc
+	}
+
+	key, _ := setKeys(meta.keys, values)
+	return meta.descs[key]
+}
+
+// MetricLabels return label pairs for metric with given label values. Empty label values are
+// omitted when Opts.Unchecked is set so it stay consistent with MetricDesc. Metric constructed by
+// this vector should use it as label in its Write. It is safe to call without holding any lock.
+func (v *Vector) MetricLabels(values []string) []*dto.LabelPair {
+	meta := v.meta()
+
+	lbl := make(prometheus.Labels, len(meta.keys)+len(meta.opts.ConstLabels))
+	for i, name := range meta.keys {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		if value != "" || !v.opts.Unchecked {
+			lbl[name] = value
+		}
+	}
+	for name, value := range meta.opts.ConstLabels {
+		lbl[name] = value
+	}
+
+	return labelsToProto(lbl)
+}
+
+// meta return current vectorMeta.
+func (v *Vector) meta() *vectorMeta {
+	return v.snapshot.Load().(*vectorMeta)
+}
+
+// updateMeta store new vectorMeta from current label keys and opts. opts must not be modified
+// afterward. It must be called with write lock.
+func (v *Vector) updateMeta(opts Opts, descs map[string]*prometheus.Desc) {
+	v.snapshot.Store(&vectorMeta{
+		desc:  newDesc(opts, v.labels.Keys),
+		descs: descs,
+		keys:  append([]string(nil), v.labels.Keys...),
+		opts:  opts,
+	})
+}
+
+// setKeys return label keys that have non empty value and its identifier.
+func setKeys(keys, values []string) (string, []string) {
+	var names []string
+	for i, value := range values {
+		if value != "" && i < len(keys) {
+			names = append(names, keys[i])
+		}
+	}
+
+	return strings.Join(names, "\xff"), names
+}
 
-	return metric
+// evict delete metric m with hash h if it is still in vector.
+func (v *Vector) evict(h uint64, m Metric) bool {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.metrics[h] != m {
+		return false
+	}
+	v.remove(h, m)
+
+	return true
+}
+
+// remove delete metric m with hash h from vector.
+func (v *Vector) remove(h uint64, m Metric) {
+	delete(v.metrics, h)
+	v.bytes -= metricSize(m)
+	v.release(1)
+}
+
+// release give back n metrics to budget.
+func (v *Vector) release(n int) {
+	if v.opts.Budget != nil && n > 0 {
+		v.opts.Budget.release(v.root, n)
+	}
 }
 
 func (v *Vector) reset() {
 	v.metrics = make(map[uint64]Metric)
-	v.labels = NewLabels(v.opts.ConstLabels)
-	v.desc = v.newDesc()
+	v.bytes = 0
+	v.labels = NewLabels(v.meta().opts.ConstLabels)
+	v.keysGen++
+	for _, name := range v.opts.LabelNames {
+		v.labels.addKey(name)
+	}
+	v.updateMeta(v.meta().opts, make(map[string]*prometheus.Desc))
 }
 
 func (v *Vector) exceedMaxLength() bool {
-	return v.opts.MaxLength > 0 && v.Length() > v.opts.MaxLength
+	max := v.meta().opts.MaxLength
+	return max > 0 && v.Length() > max
 }
 
 func (v *Vector) isExpire(lastEdit time.Time) bool {
-	return v.opts.Expire != 0 && time.Since(lastEdit) > v.opts.Expire
+	expire := v.meta().opts.Expire
+	return expire != 0 && time.Since(lastEdit) > expire
 }
 
-func (v *Vector) newDesc() *prometheus.Desc {
+func newDesc(opts Opts, keys []string) *prometheus.Desc {
 	return prometheus.NewDesc(
-		prometheus.BuildFQName(v.opts.Namespace, v.opts.Subsystem, v.opts.Name),
-		v.opts.Help,
-		v.labels.Keys,
-		v.opts.ConstLabels,
+		prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+		opts.Help,
+		keys,
+		opts.ConstLabels,
 	)
 }
 
+// gcHistoryLength is number of GC runs kept by vector.
+const gcHistoryLength = 10
+
+// GCRecord is a record of a GC run.
+type GCRecord struct {
+	GCStat
+
+	// Time is when GC started and Duration is how long it took.
+	Time     time.Time
+	Duration time.Duration
+}
+
+// GCHistory return records of last GC runs, oldest first.
+func (v *Vector) GCHistory() []GCRecord {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	return append([]GCRecord(nil), v.gcHistory...)
+}
+
+func (v *Vector) recordGC(start time.Time, stat GCStat) {
+	if len(v.gcHistory) >= gcHistoryLength {
+		v.gcHistory = append(v.gcHistory[:0], v.gcHistory[1:]...)
+	}
+	v.gcHistory = append(v.gcHistory, GCRecord{GCStat: stat, Time: start, Duration: time.Since(start)})
+}
+
+func copyLabels(l prometheus.Labels) prometheus.Labels {
+	if l == nil {
+		return nil
+	}
+
+	c := make(prometheus.Labels, len(l))
+	for k, v := range l {
+		c[k] = v
+	}
+	return c
+}
+
 // GCStat is status for garbage collector.
 type GCStat struct {
 	// Number of deleted metrics
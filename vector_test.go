@@ -6,6 +6,8 @@
 package dynamicvector_test
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -42,6 +44,25 @@ func TestVector_GetMetricWith_Limit(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestVector_WithLabelValues(t *testing.T) {
+	v := dynamicvector.NewVector(dynamicvector.Opts{Name: "vector", Help: "help", LabelNames: []string{"method", "code"}}, newMetric)
+
+	m1 := v.WithLabelValues("GET", "200")
+	assert.Equal(t, m1, v.With(prometheus.Labels{"method": "GET", "code": "200"}))
+	assert.Equal(t, 1, v.Length())
+
+	// dynamic key is still supported and does not change position of declared keys.
+	m2 := v.With(prometheus.Labels{"method": "GET", "code": "200", "user": "a"})
+	assert.NotEqual(t, m1, m2)
+	assert.Equal(t, m1, v.WithLabelValues("GET", "200"))
+
+	m3 := v.WithLabelValues("", "500")
+	assert.Equal(t, m3, v.With(prometheus.Labels{"code": "500"}))
+	assert.Equal(t, 3, v.Length())
+
+	assert.Panics(t, func() { v.WithLabelValues("GET") })
+}
+
 func TestVector_With_NoPanic(t *testing.T) {
 	v := createVector(0, 0)
 
@@ -163,6 +184,59 @@ func TestVector_Describe(t *testing.T) {
 	assert.NotEqual(t, d3, d4)
 }
 
+func TestVector_Describe_Unchecked(t *testing.T) {
+	v := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "vector", Help: "testing", Unchecked: true})
+	v.With(prometheus.Labels{"label3": "value3"})
+
+	ch := make(chan *prometheus.Desc, 10)
+	v.Describe(ch)
+	close(ch)
+
+	assert.Equal(t, 0, len(ch))
+}
+
+func TestVector_Collect_Registry(t *testing.T) {
+	registries := map[string]*prometheus.Registry{
+		"default":  prometheus.NewRegistry(),
+		"pedantic": prometheus.NewPedanticRegistry(),
+	}
+
+	for name, reg := range registries {
+		opts := dynamicvector.Opts{
+			Help:        "testing",
+			ConstLabels: prometheus.Labels{"label1": "value1"},
+			Buckets:     []float64{1, 10, 100},
+			Unchecked:   true,
+		}
+		opts.Name = "counter"
+		cv := dynamicvector.NewCounter(opts)
+		opts.Name = "gauge"
+		gv := dynamicvector.NewGauge(opts)
+		opts.Name = "histogram"
+		hv := dynamicvector.NewHistogram(opts)
+		reg.MustRegister(cv, gv, hv)
+
+		for _, lbl := range []prometheus.Labels{{}, {"label3": "value3"}, {"label4": "value4"}, {"label3": "value3", "label4": "value4"}} {
+			cv.With(lbl).Inc()
+			gv.With(lbl).Set(1)
+			hv.With(lbl).Observe(1)
+
+			mfs, err := reg.Gather()
+			assert.NoError(t, err, name)
+			assert.Equal(t, 3, len(mfs), name)
+		}
+
+		mfs, _ := reg.Gather()
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				for _, lp := range m.Label {
+					assert.NotEqual(t, "", lp.GetValue(), name)
+				}
+			}
+		}
+	}
+}
+
 func TestVector_GC_Expire(t *testing.T) {
 	v := createVector(50*time.Millisecond, 0)
 
@@ -193,6 +267,151 @@ func TestVector_GC_LimitExceeded(t *testing.T) {
 	assert.Equal(t, 2, v.Length())
 }
 
+func TestVector_Update(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{
+		Name:        "histogram_vector",
+		Help:        "old",
+		ConstLabels: prometheus.Labels{"env": "dev"},
+		Buckets:     []float64{1},
+		Unchecked:   true,
+	})
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(hv))
+	hv.With(prometheus.Labels{"key": "old"}).Observe(1)
+
+	err := hv.Update(func(opts *dynamicvector.Opts) {
+		opts.Help = "new"
+		opts.ConstLabels["env"] = "prod"
+		opts.Buckets = []float64{1, 2}
+		opts.MaxLength = 10
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", hv.Opts().Help)
+	assert.Equal(t, 10, hv.Opts().MaxLength)
+	hv.With(prometheus.Labels{"key": "new"}).Observe(1)
+
+	mfs, err := reg.Gather()
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(mfs)) {
+		assert.Equal(t, "new", mfs[0].GetHelp())
+		for _, m := range mfs[0].Metric {
+			assert.Equal(t, "prod", m.Label[0].GetValue())
+		}
+		// metrics are sorted by label, key="new" come first.
+		assert.Equal(t, 2, len(mfs[0].Metric[0].Histogram.Bucket))
+		assert.Equal(t, 1, len(mfs[0].Metric[1].Histogram.Bucket))
+	}
+}
+
+func TestVector_Update_Invalid(t *testing.T) {
+	v := createVector(0, 1)
+
+	err := v.Update(func(opts *dynamicvector.Opts) {
+		opts.Name = "other"
+		opts.MaxLength = 10
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, v.Opts().MaxLength)
+}
+
+func TestVector_Update_MaxLength(t *testing.T) {
+	v := createVector(0, 1)
+	v.With(prometheus.Labels{"label3": "a"})
+	v.With(prometheus.Labels{"label3": "b"})
+	assert.True(t, v.GC().LimitExceeded)
+	_, err := v.GetMetricWith(prometheus.Labels{"label3": "a"})
+	assert.Error(t, err)
+
+	assert.NoError(t, v.Update(func(opts *dynamicvector.Opts) { opts.MaxLength = 10 }))
+	assert.Equal(t, 0, v.Length())
+	_, err = v.GetMetricWith(prometheus.Labels{"label3": "a"})
+	assert.NoError(t, err)
+}
+
+func TestVector_Update_Concurrent(t *testing.T) {
+	cv := dynamicvector.NewCounter(dynamicvector.CounterOpts{Name: "counter_vector", Help: "help", Unchecked: true})
+	reg := prometheus.NewPedanticRegistry()
+	assert.NoError(t, reg.Register(cv))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			cv.Update(func(opts *dynamicvector.Opts) {
+				opts.ConstLabels = prometheus.Labels{"env": fmt.Sprint(cv.Opts().Help, i)}
+			})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		cv.With(prometheus.Labels{"key": fmt.Sprint(i % 10)}).Inc()
+		_, err := reg.Gather()
+		assert.NoError(t, err)
+	}
+	<-done
+}
+
+func TestVector_Update_InPlace(t *testing.T) {
+	v := dynamicvector.NewVector(dynamicvector.Opts{
+		Name:          "vector",
+		LabelNames:    []string{"a"},
+		KeyCreateRate: map[string]float64{"a": 1},
+	}, newMetric)
+
+	assert.Error(t, v.Update(func(opts *dynamicvector.Opts) { opts.LabelNames[0] = "b" }))
+	assert.Error(t, v.Update(func(opts *dynamicvector.Opts) { opts.KeyCreateRate["a"] = 2 }))
+	assert.Equal(t, []string{"a"}, v.Opts().LabelNames)
+	assert.Equal(t, map[string]float64{"a": 1}, v.Opts().KeyCreateRate)
+}
+
+func TestVector_Update_Buckets(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "histogram_vector", Buckets: []float64{1}})
+
+	assert.Error(t, hv.Update(func(opts *dynamicvector.Opts) { opts.Buckets = []float64{2, 1} }))
+	assert.Error(t, hv.Update(func(opts *dynamicvector.Opts) { opts.Buckets = []float64{1, 1} }))
+	assert.Equal(t, []float64{1}, hv.Opts().Buckets)
+}
+
+func TestVector_Update_Serialized(t *testing.T) {
+	hv := dynamicvector.NewHistogram(dynamicvector.HistogramOpts{Name: "histogram_vector", Buckets: []float64{1}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				hv.Update(func(opts *dynamicvector.Opts) {
+					opts.MaxLength++
+					opts.Buckets = append(opts.Buckets, opts.Buckets[len(opts.Buckets)-1]+1)
+				})
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		hv.With(prometheus.Labels{"key": fmt.Sprint(i)}).Observe(1)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, hv.Opts().MaxLength)
+	assert.Equal(t, 101, len(hv.Opts().Buckets))
+}
+
+func TestVector_GCHistory(t *testing.T) {
+	v := createVector(0, 1)
+	assert.Empty(t, v.GCHistory())
+
+	v.With(prometheus.Labels{})
+	v.With(prometheus.Labels{"label3": "value3"})
+	for i := 0; i < 12; i++ {
+		v.GC()
+	}
+
+	history := v.GCHistory()
+	assert.Equal(t, 10, len(history))
+	assert.False(t, history[0].Time.After(history[9].Time))
+	assert.True(t, history[9].LimitExceeded)
+}
+
 type metric struct {
 	dynamicvector.Metric
 